@@ -0,0 +1,155 @@
+// Package cloid encodes and decodes the client order ids the bot puts on paste orders,
+// so several bots can share one account and each can tell its own orders apart.
+//
+// A cloid is 128 bits, written as 0x and 32 hex digits:
+//
+//	bits 127-120  magic, 0xb0
+//	bits 119-104  bot id
+//	bits 103-96   engine
+//	bits  95-88   leader index
+//	bits  87-64   sequence, telling apart orders that share a source
+//	bits  63-0    source: the copy oid, tid or clearinghouse time the order follows,
+//	              or the low 64 bits of the copy cloid an ALO order mirrors
+package cloid
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const magic = 0xb0
+
+// MaxSeq is the largest sequence a cloid can carry.
+const MaxSeq = 1<<24 - 1
+
+// Engine is the part of the bot that placed an order.
+type Engine uint8
+
+const (
+	EngineIoc Engine = iota + 1
+	EngineAlo
+	EngineReconcile
+	EngineManual
+	EngineTrigger
+	EngineFill
+)
+
+func (e Engine) String() string {
+	switch e {
+	case EngineIoc:
+		return "ioc"
+	case EngineAlo:
+		return "alo"
+	case EngineReconcile:
+		return "reconcile"
+	case EngineManual:
+		return "manual"
+	case EngineTrigger:
+		return "trigger"
+	case EngineFill:
+		return "fill"
+	}
+	return fmt.Sprintf("engine(%d)", uint8(e))
+}
+
+type ID struct {
+	BotID  uint16
+	Engine Engine
+	Leader uint8
+	// Seq is truncated to its low 24 bits.
+	Seq    uint32
+	Source uint64
+}
+
+// String encodes id as a cloid.
+func (id ID) String() string {
+	var raw [16]byte
+	raw[0] = magic
+	binary.BigEndian.PutUint16(raw[1:3], id.BotID)
+	raw[3] = byte(id.Engine)
+	raw[4] = id.Leader
+	raw[5] = byte(id.Seq >> 16)
+	raw[6] = byte(id.Seq >> 8)
+	raw[7] = byte(id.Seq)
+	binary.BigEndian.PutUint64(raw[8:], id.Source)
+	return "0x" + hex.EncodeToString(raw[:])
+}
+
+// Label renders id compactly for the TUI and logs, e.g. "alo#1234", "reconcile#1234.2"
+// with a sequence, or "ioc@1#1234" for a leader other than the first.
+func (id ID) Label() string {
+	engine := id.Engine.String()
+	if id.Leader != 0 {
+		engine = fmt.Sprintf("%s@%d", engine, id.Leader)
+	}
+	if id.Seq != 0 {
+		return fmt.Sprintf("%s#%d.%d", engine, id.Source, id.Seq)
+	}
+	return fmt.Sprintf("%s#%d", engine, id.Source)
+}
+
+// Decode parses a cloid minted by String. ok is false for any other cloid.
+func Decode(cloid string) (id ID, ok bool) {
+	raw, ok := parse(cloid)
+	if !ok || raw[0] != magic {
+		return ID{}, false
+	}
+	return ID{
+		BotID:  binary.BigEndian.Uint16(raw[1:3]),
+		Engine: Engine(raw[3]),
+		Leader: raw[4],
+		Seq:    uint32(raw[5])<<16 | uint32(raw[6])<<8 | uint32(raw[7]),
+		Source: binary.BigEndian.Uint64(raw[8:]),
+	}, true
+}
+
+// Owned reports whether cloid was minted by bot botID, by one of engines if any are
+// given.
+func Owned(cloid string, botID uint16, engines ...Engine) bool {
+	id, ok := Decode(cloid)
+	if !ok || id.BotID != botID {
+		return false
+	}
+	if len(engines) == 0 {
+		return true
+	}
+	for _, engine := range engines {
+		if id.Engine == engine {
+			return true
+		}
+	}
+	return false
+}
+
+// Low64 is the low 64 bits of any cloid, or 0 if it doesn't parse. For a cloid minted
+// by String that is its source, so a paste ALO cloid and the copy cloid it mirrors share
+// their Low64.
+func Low64(cloid string) uint64 {
+	raw, ok := parse(cloid)
+	if !ok {
+		return 0
+	}
+	return binary.BigEndian.Uint64(raw[8:])
+}
+
+// Label renders cloid with ID.Label when it decodes, or as-is.
+func Label(cloid string) string {
+	if id, ok := Decode(cloid); ok {
+		return id.Label()
+	}
+	return cloid
+}
+
+func parse(cloid string) (raw [16]byte, ok bool) {
+	digits := strings.TrimPrefix(strings.ToLower(cloid), "0x")
+	if digits == "" || len(digits) > 32 {
+		return raw, false
+	}
+	digits = strings.Repeat("0", 32-len(digits)) + digits
+	if _, err := hex.Decode(raw[:], []byte(digits)); err != nil {
+		return raw, false
+	}
+	return raw, true
+}
@@ -0,0 +1,91 @@
+package cloid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ids := []ID{
+		{BotID: 0, Engine: EngineIoc, Source: 1},
+		{BotID: 7, Engine: EngineAlo, Leader: 2, Source: 0xdeadbeefcafebabe},
+		{BotID: 65535, Engine: EngineReconcile, Seq: MaxSeq, Source: 1<<64 - 1},
+		{BotID: 42, Engine: EngineFill, Leader: 255, Seq: 12, Source: 1700000000000},
+	}
+	for _, id := range ids {
+		encoded := id.String()
+		if len(encoded) != 34 || !strings.HasPrefix(encoded, "0x") {
+			t.Errorf("%+v encodes to %q, want 0x and 32 hex digits", id, encoded)
+		}
+		decoded, ok := Decode(encoded)
+		if !ok {
+			t.Errorf("Decode(%q) failed", encoded)
+			continue
+		}
+		if decoded != id {
+			t.Errorf("Decode(%q) = %+v, want %+v", encoded, decoded, id)
+		}
+		if got := Low64(encoded); got != id.Source {
+			t.Errorf("Low64(%q) = %d, want %d", encoded, got, id.Source)
+		}
+	}
+}
+
+func TestSeqTruncated(t *testing.T) {
+	decoded, _ := Decode(ID{Engine: EngineReconcile, Seq: MaxSeq + 2}.String())
+	if decoded.Seq != 1 {
+		t.Errorf("Seq = %d, want the low 24 bits 1", decoded.Seq)
+	}
+}
+
+func TestDecodeRejectsForeignCloids(t *testing.T) {
+	for _, cloid := range []string{
+		"",
+		"0x",
+		"0x00000000000000000000000000000001",
+		"0xa0000000000000000000000000000001",
+		"0xb0zz0000000000000000000000000001",
+		"0xb00000000000000000000000000000000001",
+	} {
+		if id, ok := Decode(cloid); ok {
+			t.Errorf("Decode(%q) = %+v, want !ok", cloid, id)
+		}
+	}
+}
+
+func TestOwned(t *testing.T) {
+	cloid := ID{BotID: 3, Engine: EngineAlo, Source: 9}.String()
+	tests := []struct {
+		botID   uint16
+		engines []Engine
+		want    bool
+	}{
+		{3, nil, true},
+		{3, []Engine{EngineAlo}, true},
+		{3, []Engine{EngineIoc, EngineAlo}, true},
+		{3, []Engine{EngineIoc}, false},
+		{4, nil, false},
+	}
+	for _, tt := range tests {
+		if got := Owned(cloid, tt.botID, tt.engines...); got != tt.want {
+			t.Errorf("Owned(bot %d, %v) = %v, want %v", tt.botID, tt.engines, got, tt.want)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		cloid string
+		want  string
+	}{
+		{ID{Engine: EngineAlo, Source: 1234}.String(), "alo#1234"},
+		{ID{Engine: EngineReconcile, Seq: 2, Source: 1234}.String(), "reconcile#1234.2"},
+		{ID{Engine: EngineIoc, Leader: 1, Source: 1234}.String(), "ioc@1#1234"},
+		{"0x1234", "0x1234"},
+	}
+	for _, tt := range tests {
+		if got := Label(tt.cloid); got != tt.want {
+			t.Errorf("Label(%q) = %q, want %q", tt.cloid, got, tt.want)
+		}
+	}
+}
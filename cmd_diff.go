@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/ws"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Print the IOC orders a reconcile would send right now, without sending them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		manager, err := snapshotManager(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer manager.Close()
+		printDiff(manager)
+		for _, child := range manager.Children {
+			fmt.Println()
+			printDiff(child)
+		}
+		return nil
+	},
+}
+
+func init() {
+	addSnapshotFlags(diffCmd)
+}
+
+func printDiff(manager *ws.Manager) {
+	fmt.Printf("paste %s\n", manager.PasteAddress)
+	requests := manager.ReconcileRequests()
+	if len(requests) == 0 {
+		fmt.Println("in sync, nothing to send")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COIN\tSIDE\tSIZE\tLIMIT PX\tREDUCE ONLY\tTIF")
+	for _, request := range requests {
+		side := "SELL"
+		if request.IsBuy {
+			side = "BUY"
+		}
+		tif := ""
+		if request.OrderType.Limit != nil {
+			tif = string(request.OrderType.Limit.Tif)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%g\t%g\t%v\t%s\n", request.Coin, side, request.Sz, request.LimitPx, request.ReduceOnly, tif)
+	}
+	w.Flush()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/ws"
+	"github.com/spf13/cobra"
+)
+
+var flattenCmd = &cobra.Command{
+	Use:   "flatten [coin]",
+	Short: "Cancel paste orders and close paste positions on one coin, or all of them",
+	Long: "Cancel paste orders and close paste positions on one coin, or on every coin when\n" +
+		"none is given, across the primary and every child paste account. Stop a running\n" +
+		"bot first or it will rebuild the positions.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		coin := ""
+		if len(args) == 1 {
+			coin = args[0]
+		}
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return err
+		}
+		if err := cfg.RequireLive(); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		manager, err := snapshotManager(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer manager.Close()
+		if _, configured := manager.CoinRiskMap()[coin]; coin != "" && !configured {
+			return fmt.Errorf("coin %s is not configured", coin)
+		}
+		var errs []error
+		for _, account := range append([]*ws.Manager{manager}, manager.Children...) {
+			if err := account.Flatten(coin); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", account.PasteAddress, err))
+				continue
+			}
+			fmt.Printf("flattened %s\n", account.PasteAddress)
+		}
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	addSnapshotFlags(flattenCmd)
+}
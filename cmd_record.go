@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var recordCmd = &cobra.Command{
+	Use:   "record <file>",
+	Short: "Run the bot, appending every raw websocket frame to a gzip recording",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recordPath = args[0]
+		return runBot()
+	},
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Feed a recording through the bot against a simulated paste account",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		replayPath = args[0]
+		return runBot()
+	},
+}
+
+func init() {
+	recordCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate the paste account locally instead of trading")
+	recordCmd.Flags().BoolVar(&headless, "headless", false, "Run without the TUI, logging JSON lines to stdout and the log file")
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Replay speed multiplier, 0 replays as fast as possible")
+	replayCmd.Flags().BoolVar(&headless, "headless", false, "Run without the TUI, logging JSON lines to stdout and the log file")
+}
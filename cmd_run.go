@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	_ "net/http/pprof" // registers pprof handlers
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"syscall"
+	"time"
+
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/control"
+	"github.com/itay747/hyperformance/metrics"
+	"github.com/itay747/hyperformance/tui"
+	"github.com/itay747/hyperformance/utils"
+	"github.com/itay747/hyperformance/ws"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dryRun      bool
+	recordPath  string
+	replayPath  string
+	replaySpeed float64
+	headless    bool
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the bot",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBot()
+	},
+}
+
+func init() {
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate the paste account locally instead of trading")
+	runCmd.Flags().StringVar(&recordPath, "record", "", "Append every raw websocket frame to this gzip file")
+	runCmd.Flags().StringVar(&replayPath, "replay", "", "Replay a recording instead of connecting (implies --dry-run)")
+	runCmd.Flags().Float64Var(&replaySpeed, "replay-speed", 1, "Replay speed multiplier, 0 replays as fast as possible")
+	runCmd.Flags().BoolVar(&headless, "headless", false, "Run without the TUI, logging JSON lines to stdout and the log file")
+}
+
+// runBot runs the engines until the TUI quits or, headless, until SIGINT or SIGTERM.
+func runBot() error {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile("block.prof", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open block.prof: %w", err)
+	}
+	defer f.Close()
+	runtime.SetBlockProfileRate(1)
+
+	// Expose pprof endpoints at http://localhost:6060/debug/pprof/
+	go func() {
+		log.Println(http.ListenAndServe("localhost:6060", nil))
+	}()
+	runtime.SetBlockProfileRate(1)
+	// run code here that might block
+	pprof.Lookup("block").WriteTo(f, 0)
+	logChannel := make(chan string, 10000)
+	if headless {
+		out, closeLog, err := openHeadlessLog(cfg.Log)
+		if err != nil {
+			return err
+		}
+		defer closeLog()
+		jsonLogger := utils.NewJSONLogger(out)
+		log.SetOutput(jsonLogger)
+		ws.SetLogger(jsonLogger)
+	} else {
+		log.SetOutput(tui.ChannelLogWriter{LogChannel: logChannel})
+		ws.SetLogger(utils.NewDualLogger(logChannel))
+	}
+	parentCtx := context.Background()
+	if headless {
+		// without the TUI nothing handles ctrl+c, so stop cleanly on signals
+		var stop context.CancelFunc
+		parentCtx, stop = signal.NotifyContext(parentCtx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
+	ctx, cancel := context.WithCancel(parentCtx)
+	manager, err := newManager(ctx, cfg, ws.ManagerOptions{
+		DryRun:      dryRun,
+		RecordPath:  recordPath,
+		ReplayPath:  replayPath,
+		ReplaySpeed: replaySpeed,
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+	// One line to start one session that handles both copy and paste
+	go manager.StartCopyTradingSession(ctx)
+
+	manager.StartLogging(10000)
+	oldAddLog := manager.AddLogFunc
+	manager.AddLogFunc = func(addr, message string) {
+		ts := time.Now().Format("15:04:05")
+		enriched := fmt.Sprintf("[%s] %s", ts, message)
+		oldAddLog(addr, enriched)
+	}
+
+	manager.StartEngines(ctx)
+
+	go handleBreakerSignals(ctx, manager)
+	if controlAddr := manager.Config.ControlAddr; controlAddr != "" {
+		if err := control.NewServer(manager, controlAddr).Start(ctx); err != nil {
+			cancel()
+			return err
+		}
+	}
+	if metricsAddr := manager.Config.MetricsAddr; metricsAddr != "" {
+		manager.RegisterMetrics()
+		if err := metrics.Start(ctx, metricsAddr); err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	if headless {
+		<-ctx.Done()
+	} else {
+		go pollLogs(manager, logChannel)
+		if err := tui.RunTUI(
+			ctx,
+			manager,
+			logChannel,
+			25*time.Millisecond,
+		); err != nil {
+			fmt.Println("Error in TUI:", err)
+		}
+	}
+	cancel()
+	if err := manager.Close(); err != nil {
+		return fmt.Errorf("close manager: %w", err)
+	}
+	return nil
+}
+
+// openHeadlessLog returns stdout, teed into the rotating log file when one is configured.
+func openHeadlessLog(logConfig config.LogConfig) (io.Writer, func() error, error) {
+	if logConfig.File == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	maxSizeMB := logConfig.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = 100
+	}
+	maxBackups := logConfig.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = 5
+	}
+	file, err := utils.NewRotatingFile(logConfig.File, int64(maxSizeMB)<<20, maxBackups)
+	if err != nil {
+		return nil, nil, err
+	}
+	return io.MultiWriter(os.Stdout, file), file.Close, nil
+}
+
+// handleBreakerSignals trips every paste account's breaker on SIGUSR1 and resumes them
+// on SIGUSR2.
+func handleBreakerSignals(ctx context.Context, managerRef *ws.Manager) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			if sig == syscall.SIGUSR1 {
+				managerRef.TripAll("SIGUSR1 kill switch")
+			} else {
+				managerRef.ResumeAll()
+			}
+		}
+	}
+}
+
+func pollLogs(managerRef *ws.Manager, output chan<- string) {
+	t := time.NewTicker((1000 / 200) * time.Millisecond)
+	defer t.Stop()
+	for range t.C {
+		copyLines := managerRef.GetLogs(managerRef.CopyAddress, 1)
+		if len(copyLines) > 0 {
+			output <- copyLines[len(copyLines)-1]
+		}
+		pasteLines := managerRef.GetLogs(managerRef.PasteAddress, 1)
+		if len(pasteLines) > 0 {
+			output <- pasteLines[len(pasteLines)-1]
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/ws"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print copy and paste positions and the drift between them, then exit",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		manager, err := snapshotManager(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer manager.Close()
+		printStatus(manager)
+		for _, child := range manager.Children {
+			fmt.Println()
+			printStatus(child)
+		}
+		return nil
+	},
+}
+
+func init() {
+	addSnapshotFlags(statusCmd)
+}
+
+func printStatus(manager *ws.Manager) {
+	fmt.Printf("copy  %s  account value %.2f\n", manager.CopyAddress, manager.CopyWd2.AccountValue())
+	fmt.Printf("paste %s  account value %.2f\n", manager.PasteAddress, manager.PasteWd2.AccountValue())
+	copyPositions := manager.CopyWd2.PositionsByCoin()
+	pastePositions := manager.PasteWd2.PositionsByCoin()
+	targets := manager.TargetPositions()
+	drift := manager.DriftNotional()
+	coins := make([]string, 0, len(drift))
+	for coin := range drift {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+	var total float64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "COIN\tMID\tCOPY SZI\tTARGET SZI\tPASTE SZI\tDRIFT USD\t")
+	for _, coin := range coins {
+		fmt.Fprintf(w, "%s\t%g\t%g\t%g\t%g\t%.2f\t\n", coin, manager.GetMidPrice(coin),
+			copyPositions[coin].Szi, targets[coin].Szi, pastePositions[coin].Szi, drift[coin])
+		total += math.Abs(drift[coin])
+	}
+	fmt.Fprintf(w, "TOTAL\t\t\t\t\t%.2f\t\n", total)
+	w.Flush()
+}
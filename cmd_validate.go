@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/spf13/cobra"
+)
+
+var offline bool
+
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate-config",
+	Short: "Check the config file and exit",
+	Long: "Check the config file, its secret keys and, unless --offline, that every coin is\n" +
+		"listed on the exchange.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return err
+		}
+		if !offline {
+			// InfoAPI.BuildMetaMap exits the process on a failed request
+			response, err := hl.NewInfoAPI(true).GetMeta()
+			if err != nil {
+				return fmt.Errorf("fetch meta: %w", err)
+			}
+			meta, _ := config.BuildMetaMap(response)
+			if err := cfg.ValidateCoins(meta); err != nil {
+				return fmt.Errorf("invalid config:\n%w", err)
+			}
+		}
+		if err := cfg.RequireLive(); err != nil {
+			fmt.Printf("dry run only: %v\n", err)
+		}
+		fmt.Printf("config ok: %d coins, %d leaders, %d extra paste accounts\n",
+			len(cfg.CoinRiskMap), max(len(cfg.Leaders), 1), len(cfg.PasteAccounts))
+		return nil
+	},
+}
+
+func init() {
+	validateConfigCmd.Flags().BoolVar(&offline, "offline", false, "Skip checking the coins against the exchange")
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -11,13 +12,155 @@ import (
 )
 
 type HyperformanceConfig struct {
-	Comments         string             `json:"comments"`
-	SecretKey        string             `json:"secret_key"`
-	CopyAddress      string             `json:"copy_address,omitempty"`
-	PasteAddress     string             `json:"account_address"`
-	CoinRiskMap      map[string]float64 `json:"coins"`
-	DisableAloEngine bool               `json:"disable_alo_engine,omitempty"`
-	DisableIocEngine bool               `json:"disable_ioc_engine,omitempty"`
+	Comments            string                  `json:"comments"`
+	SecretKey           string                  `json:"secret_key"`
+	SecretKeyFile       string                  `json:"secret_key_file,omitempty"`
+	CopyAddress         string                  `json:"copy_address,omitempty"`
+	PasteAddress        string                  `json:"account_address"`
+	CoinRiskMap         map[string]float64      `json:"coins"`
+	Leaders             []LeaderConfig          `json:"leaders,omitempty"`
+	PasteAccounts       []PasteAccountConfig    `json:"paste_accounts,omitempty"`
+	Sizing              map[string]SizingConfig `json:"sizing,omitempty"`
+	DisableAloEngine    bool                    `json:"disable_alo_engine,omitempty"`
+	DisableIocEngine    bool                    `json:"disable_ioc_engine,omitempty"`
+	EnableFillEngine    bool                    `json:"enable_fill_engine,omitempty"`
+	EnableTriggerEngine bool                    `json:"enable_trigger_engine,omitempty"`
+	BotID               uint16                  `json:"bot_id,omitempty"`
+	ControlAddr         string                  `json:"control_addr,omitempty"`
+	MetricsAddr         string                  `json:"metrics_addr,omitempty"`
+	WsEndpoint          string                  `json:"ws_endpoint,omitempty"`
+	StartupPolicy       string                  `json:"startup_policy,omitempty"`
+	BookNSigFigs        int                     `json:"book_n_sig_figs,omitempty"`
+	SlippageBps         map[string]float64      `json:"slippage_bps,omitempty"`
+	SlippageOverflow    string                  `json:"slippage_overflow,omitempty"`
+	Risk                RiskConfig              `json:"risk,omitempty"`
+	Breaker             BreakerConfig           `json:"breaker,omitempty"`
+	StateFile           string                  `json:"state_file,omitempty"`
+	Log                 LogConfig               `json:"log,omitempty"`
+	StateRetentionHours int                     `json:"state_retention_hours,omitempty"`
+	SyncLeverage        bool                    `json:"sync_leverage,omitempty"`
+	MaxLeverage         int                     `json:"max_leverage,omitempty"`
+
+	DisableReconcileLoop   bool `json:"disable_reconcile_loop,omitempty"`
+	ReconcileIntervalMs    int  `json:"reconcile_interval_ms,omitempty"`
+	ReconcileConfirmations int  `json:"reconcile_confirmations,omitempty"`
+	InFlightTtlMs          int  `json:"in_flight_ttl_ms,omitempty"`
+
+	PaperBalance     float64 `json:"paper_balance,omitempty"`
+	PaperTakerFeeBps float64 `json:"paper_taker_fee_bps,omitempty"`
+	PaperMakerFeeBps float64 `json:"paper_maker_fee_bps,omitempty"`
+}
+
+// LeaderConfig is one copy account to follow. Weight scales the leader's contribution to
+// the blended paste target, Coins overrides the top-level coins risk map for this leader.
+type LeaderConfig struct {
+	Address string             `json:"address"`
+	Weight  float64            `json:"weight,omitempty"`
+	Coins   map[string]float64 `json:"coins,omitempty"`
+}
+
+// PasteAccountConfig is an additional paste account driven from the same copy stream.
+// Scale multiplies its scale factor, Coins replaces the top-level coins map.
+type PasteAccountConfig struct {
+	Address       string             `json:"account_address"`
+	SecretKey     string             `json:"secret_key"`
+	SecretKeyFile string             `json:"secret_key_file,omitempty"`
+	Scale         float64            `json:"scale,omitempty"`
+	Coins         map[string]float64 `json:"coins,omitempty"`
+}
+
+// RiskConfig limits every outbound paste order. Zero leaves a limit off. Available margin
+// and the exchange's max trade sizes are always enforced.
+type RiskConfig struct {
+	MaxOrderNotional   float64 `json:"max_order_notional,omitempty"`
+	MaxOrdersPerMinute int     `json:"max_orders_per_minute,omitempty"`
+	MaxAccountLeverage float64 `json:"max_account_leverage,omitempty"`
+	// MaxPositionNotional is keyed by coin, with "*" as the fallback.
+	MaxPositionNotional map[string]float64 `json:"max_position_notional,omitempty"`
+}
+
+// BreakerConfig trips the circuit breaker, halting both engines until an operator
+// resumes them. Zero leaves a threshold off.
+type BreakerConfig struct {
+	// MaxDrawdownPct is measured from the paste account value's peak of the UTC day.
+	MaxDrawdownPct float64 `json:"max_drawdown_pct,omitempty"`
+	// MaxLoss is in USD from the paste account value at the start of the UTC day.
+	MaxLoss                 float64 `json:"max_loss,omitempty"`
+	HaltOnLeaderLiquidation bool    `json:"halt_on_leader_liquidation,omitempty"`
+	// MaxTrackingErrorPct is the paste notional off the copy target, as a percentage of
+	// the paste account value, sustained for TrackingErrorSeconds (default 30).
+	MaxTrackingErrorPct  float64 `json:"max_tracking_error_pct,omitempty"`
+	TrackingErrorSeconds int     `json:"tracking_error_seconds,omitempty"`
+	CancelOnTrip         bool    `json:"cancel_on_trip,omitempty"`
+	FlattenOnTrip        bool    `json:"flatten_on_trip,omitempty"`
+}
+
+// LogConfig is where --headless writes its JSON log besides stdout. The file rotates at
+// MaxSizeMB (default 100), keeping MaxBackups old files (default 5).
+type LogConfig struct {
+	File       string `json:"file,omitempty"`
+	MaxSizeMB  int    `json:"max_size_mb,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty"`
+}
+
+// What an IOC order does when the book can't absorb all of it within slippage_bps.
+const (
+	// SlippageSend sends the full size, limited at the worst tolerated price (default).
+	SlippageSend = "send"
+	// SlippageSplit sends only the size the book absorbs within tolerance and leaves the
+	// rest to the reconcile loop.
+	SlippageSplit = "split"
+	// SlippageSkip doesn't send the order.
+	SlippageSkip = "skip"
+)
+
+// Startup policies for the paste account's existing orders and positions.
+const (
+	// StartupFlatten cancels every order and closes every configured coin (default).
+	StartupFlatten = "flatten"
+	// StartupReconcile cancels every order and keeps positions, trading only the drift.
+	StartupReconcile = "reconcile"
+	// StartupAdopt keeps positions and cloid-tagged orders as already mirrored.
+	StartupAdopt = "adopt"
+)
+
+const (
+	SizingAccountRatio    = "account_ratio"
+	SizingFixedMultiplier = "fixed_multiplier"
+	SizingFixedNotional   = "fixed_notional"
+	SizingMarginMatch     = "margin_match"
+	SizingCappedLeverage  = "capped_leverage"
+)
+
+// SizingConfig selects how a leader size becomes a paste size for one coin, keyed by
+// coin in HyperformanceConfig.Sizing with "*" as the fallback. Value is the multiplier,
+// the USD notional, or the leverage cap, depending on Mode.
+type SizingConfig struct {
+	Mode  string  `json:"mode"`
+	Value float64 `json:"value,omitempty"`
+}
+
+// Load reads the config at path, or the one LoadConfig finds when path is "", fills in
+// the secret keys from the environment or key files, and validates it.
+func Load(path string) (*HyperformanceConfig, error) {
+	if path == "" {
+		found, err := findConfig()
+		if err != nil {
+			return nil, err
+		}
+		path = found
+	}
+	c, err := ParseConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.resolveSecrets(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s:\n%w", path, err)
+	}
+	return c, nil
 }
 
 func LoadConfigWithOverride(path string) (*HyperformanceConfig, error) {
@@ -28,12 +171,22 @@ func LoadConfigWithOverride(path string) (*HyperformanceConfig, error) {
 }
 
 func LoadConfig() (*HyperformanceConfig, error) {
+	f, err := findConfig()
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(f)
+}
+
+// findConfig returns the first config.json under the working directory, or else the
+// first config*.json under its parent.
+func findConfig() (string, error) {
 	f, err := script.FindFiles(".").
 		MatchRegexp(regexp.MustCompile(`(^|/)config\.json$`)).
 		First(1).
 		String()
 	if err != nil {
-		return nil, fmt.Errorf("find config.json: %w", err)
+		return "", fmt.Errorf("find config.json: %w", err)
 	}
 	f = strings.TrimSpace(f)
 	if f == "" {
@@ -42,14 +195,14 @@ func LoadConfig() (*HyperformanceConfig, error) {
 			First(1).
 			String()
 		if err != nil {
-			return nil, fmt.Errorf("find config*.json: %w", err)
+			return "", fmt.Errorf("find config*.json: %w", err)
 		}
 		f = strings.TrimSpace(f)
 	}
 	if f == "" {
-		return nil, fmt.Errorf("no config file found")
+		return "", fmt.Errorf("no config file found")
 	}
-	return ParseConfig(f)
+	return f, nil
 }
 
 func ParseConfig(p string) (*HyperformanceConfig, error) {
@@ -58,14 +211,17 @@ func ParseConfig(p string) (*HyperformanceConfig, error) {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
 	var c HyperformanceConfig
-	if err := json.Unmarshal([]byte(d), &c); err != nil {
-		return nil, fmt.Errorf("unmarshal config: %w", err)
+	decoder := json.NewDecoder(strings.NewReader(d))
+	// a misspelled key would otherwise silently leave its setting at the default
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, fmt.Errorf("unmarshal config %s: %w", p, err)
 	}
 	return &c, nil
 }
 
 func NewHyper() (*hl.Hyperliquid, error) {
-	cfg, err := LoadConfig()
+	cfg, err := Load("")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SecretKeyEnv, when set, is the paste account's secret key, taking precedence over
+// secret_key and secret_key_file. Extra paste accounts take theirs from the config.
+const SecretKeyEnv = "HYPERFORMANCE_SECRET_KEY"
+
+// resolveSecrets fills SecretKey from SecretKeyEnv or secret_key_file, for the paste
+// account and every extra one. A relative key file is relative to dir, the config's
+// directory.
+func (c *HyperformanceConfig) resolveSecrets(dir string) error {
+	if key := strings.TrimSpace(os.Getenv(SecretKeyEnv)); key != "" {
+		c.SecretKey = key
+	} else {
+		key, err := secretKey(c.SecretKey, c.SecretKeyFile, dir)
+		if err != nil {
+			return err
+		}
+		c.SecretKey = key
+	}
+	for i := range c.PasteAccounts {
+		account := &c.PasteAccounts[i]
+		key, err := secretKey(account.SecretKey, account.SecretKeyFile, dir)
+		if err != nil {
+			return fmt.Errorf("paste_accounts[%d]: %w", i, err)
+		}
+		account.SecretKey = key
+	}
+	return nil
+}
+
+func secretKey(inline, file, dir string) (string, error) {
+	if file == "" {
+		return inline, nil
+	}
+	if inline != "" {
+		return "", fmt.Errorf("set secret_key or secret_key_file, not both")
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read secret_key_file: %w", err)
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
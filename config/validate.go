@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+)
+
+var (
+	addressPattern   = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	secretKeyPattern = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{64}$`)
+)
+
+// MaxCoinWeight bounds a coin's weight in a coins map. Zero leaves the coin untraded.
+const MaxCoinWeight = 100
+
+// Validate reports every problem with the config it can find without reaching the
+// exchange, joined into one error. ValidateCoins checks the coins against the exchange.
+func (c *HyperformanceConfig) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	checkAddress := func(field, address string) {
+		check(addressPattern.MatchString(address), "%s %q is not a 0x-prefixed 40 digit hex address", field, address)
+	}
+	checkSecretKey := func(field, key string) {
+		check(key == "" || secretKeyPattern.MatchString(key), "%s is not a 64 digit hex private key", field)
+	}
+	checkNonNegative := func(field string, value float64) {
+		check(value >= 0 && !math.IsNaN(value), "%s must not be negative, got %v", field, value)
+	}
+	checkPct := func(field string, value float64) {
+		check(value >= 0 && value <= 100, "%s must be between 0 and 100, got %v", field, value)
+	}
+	checkCoins := func(field string, coins map[string]float64) {
+		for _, coin := range sortedKeys(coins) {
+			weight := coins[coin]
+			check(weight >= 0 && weight <= MaxCoinWeight, "%s.%s weight must be between 0 and %v, got %v",
+				field, coin, MaxCoinWeight, weight)
+		}
+	}
+
+	// addresses
+	copyAddress := c.CopyAddress
+	if copyAddress == "" && len(c.Leaders) > 0 {
+		copyAddress = c.Leaders[0].Address
+	}
+	check(copyAddress != "", "copy_address or leaders is required")
+	if c.CopyAddress != "" {
+		checkAddress("copy_address", c.CopyAddress)
+	}
+	// account_address and secret_key may be left out for a dry run; see RequireLive
+	if c.PasteAddress != "" {
+		checkAddress("account_address", c.PasteAddress)
+		check(!strings.EqualFold(c.PasteAddress, copyAddress), "account_address is the copy address")
+	}
+	checkSecretKey("secret_key", c.SecretKey)
+	for i, leader := range c.Leaders {
+		field := fmt.Sprintf("leaders[%d]", i)
+		checkAddress(field+".address", leader.Address)
+		checkNonNegative(field+".weight", leader.Weight)
+		checkCoins(field+".coins", leader.Coins)
+	}
+	pasteAddresses := map[string]bool{strings.ToLower(c.PasteAddress): true}
+	for i, account := range c.PasteAccounts {
+		field := fmt.Sprintf("paste_accounts[%d]", i)
+		checkAddress(field+".account_address", account.Address)
+		checkSecretKey(field+".secret_key", account.SecretKey)
+		check(!pasteAddresses[strings.ToLower(account.Address)], "%s.account_address %s is already a paste account", field, account.Address)
+		pasteAddresses[strings.ToLower(account.Address)] = true
+		checkNonNegative(field+".scale", account.Scale)
+		checkCoins(field+".coins", account.Coins)
+	}
+
+	// coins and sizing
+	check(len(c.CoinRiskMap) > 0, "coins is empty")
+	checkCoins("coins", c.CoinRiskMap)
+	for _, coin := range sortedKeys(c.Sizing) {
+		sizing := c.Sizing[coin]
+		switch sizing.Mode {
+		case "", SizingAccountRatio, SizingMarginMatch:
+		case SizingFixedMultiplier, SizingFixedNotional, SizingCappedLeverage:
+			check(sizing.Value > 0, "sizing.%s.value must be positive for %s", coin, sizing.Mode)
+		default:
+			check(false, "sizing.%s.mode %q is unknown", coin, sizing.Mode)
+		}
+	}
+	for _, coin := range sortedKeys(c.SlippageBps) {
+		bps := c.SlippageBps[coin]
+		check(bps > 0 && bps <= 10000, "slippage_bps.%s must be between 0 and 10000, got %v", coin, bps)
+	}
+
+	// enumerations
+	switch c.StartupPolicy {
+	case "", StartupFlatten, StartupReconcile, StartupAdopt:
+	default:
+		check(false, "startup_policy %q, want %s, %s or %s", c.StartupPolicy, StartupFlatten, StartupReconcile, StartupAdopt)
+	}
+	switch c.SlippageOverflow {
+	case "", SlippageSend, SlippageSplit, SlippageSkip:
+	default:
+		check(false, "slippage_overflow %q, want %s, %s or %s", c.SlippageOverflow, SlippageSend, SlippageSplit, SlippageSkip)
+	}
+	check(c.BookNSigFigs == 0 || c.BookNSigFigs >= 2 && c.BookNSigFigs <= 5, "book_n_sig_figs must be 2 to 5, got %d", c.BookNSigFigs)
+
+	// limits
+	checkNonNegative("risk.max_order_notional", c.Risk.MaxOrderNotional)
+	checkNonNegative("risk.max_orders_per_minute", float64(c.Risk.MaxOrdersPerMinute))
+	checkNonNegative("risk.max_account_leverage", c.Risk.MaxAccountLeverage)
+	for _, coin := range sortedKeys(c.Risk.MaxPositionNotional) {
+		checkNonNegative("risk.max_position_notional."+coin, c.Risk.MaxPositionNotional[coin])
+	}
+	checkPct("breaker.max_drawdown_pct", c.Breaker.MaxDrawdownPct)
+	checkPct("breaker.max_tracking_error_pct", c.Breaker.MaxTrackingErrorPct)
+	checkNonNegative("breaker.max_loss", c.Breaker.MaxLoss)
+	checkNonNegative("breaker.tracking_error_seconds", float64(c.Breaker.TrackingErrorSeconds))
+	checkNonNegative("max_leverage", float64(c.MaxLeverage))
+	checkNonNegative("state_retention_hours", float64(c.StateRetentionHours))
+	checkNonNegative("reconcile_interval_ms", float64(c.ReconcileIntervalMs))
+	checkNonNegative("reconcile_confirmations", float64(c.ReconcileConfirmations))
+	checkNonNegative("in_flight_ttl_ms", float64(c.InFlightTtlMs))
+	checkNonNegative("paper_balance", c.PaperBalance)
+	checkNonNegative("paper_taker_fee_bps", c.PaperTakerFeeBps)
+	checkNonNegative("paper_maker_fee_bps", c.PaperMakerFeeBps)
+	checkNonNegative("log.max_size_mb", float64(c.Log.MaxSizeMB))
+	checkNonNegative("log.max_backups", float64(c.Log.MaxBackups))
+
+	// flags that only make sense together
+	check(!c.DisableAloEngine || !c.DisableIocEngine || c.EnableFillEngine || c.EnableTriggerEngine,
+		"every engine is disabled, nothing would be mirrored")
+	check(c.MaxLeverage == 0 || c.SyncLeverage, "max_leverage is set but sync_leverage is off")
+	check(c.Breaker.TrackingErrorSeconds == 0 || c.Breaker.MaxTrackingErrorPct > 0,
+		"breaker.tracking_error_seconds is set but breaker.max_tracking_error_pct is off")
+	check(!c.Breaker.CancelOnTrip && !c.Breaker.FlattenOnTrip || c.Breaker.tripsOnAnything(),
+		"breaker.cancel_on_trip or flatten_on_trip is set but no breaker threshold is")
+	check(c.ControlAddr == "" || c.ControlAddr != c.MetricsAddr, "control_addr and metrics_addr are both %s", c.ControlAddr)
+	check(c.StateRetentionHours == 0 || c.StateFile != "", "state_retention_hours is set but state_file is not")
+	return errors.Join(errs...)
+}
+
+// RequireLive reports what trading the paste accounts needs beyond Validate: their
+// addresses and secret keys.
+func (c *HyperformanceConfig) RequireLive() error {
+	var errs []error
+	if c.PasteAddress == "" {
+		errs = append(errs, fmt.Errorf("account_address is required"))
+	}
+	if c.SecretKey == "" {
+		errs = append(errs, fmt.Errorf("secret_key, secret_key_file or %s is required", SecretKeyEnv))
+	}
+	for i, account := range c.PasteAccounts {
+		if account.SecretKey == "" {
+			errs = append(errs, fmt.Errorf("paste_accounts[%d]: secret_key or secret_key_file is required", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b BreakerConfig) tripsOnAnything() bool {
+	return b.MaxDrawdownPct > 0 || b.MaxLoss > 0 || b.HaltOnLeaderLiquidation || b.MaxTrackingErrorPct > 0
+}
+
+// ValidateCoins reports every configured coin, in any coins map, that meta doesn't list.
+func (c *HyperformanceConfig) ValidateCoins(meta map[string]hl.AssetInfo) error {
+	var errs []error
+	checkCoins := func(field string, coins map[string]float64) {
+		for _, coin := range sortedKeys(coins) {
+			if _, ok := meta[coin]; !ok {
+				errs = append(errs, fmt.Errorf("%s.%s is not a listed perp", field, coin))
+			}
+		}
+	}
+	checkCoins("coins", c.CoinRiskMap)
+	for i, leader := range c.Leaders {
+		checkCoins(fmt.Sprintf("leaders[%d].coins", i), leader.Coins)
+	}
+	for i, account := range c.PasteAccounts {
+		checkCoins(fmt.Sprintf("paste_accounts[%d].coins", i), account.Coins)
+	}
+	return errors.Join(errs...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
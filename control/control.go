@@ -0,0 +1,237 @@
+// Package control serves a local HTTP API to drive a running bot without a terminal:
+// pause and resume engines, flatten, change coin weights, enable or disable coins, and
+// read the copy and paste state.
+//
+// Every endpoint takes an optional ?account= paste address to act on a child paste
+// account instead of the primary one.
+//
+//	GET  /state
+//	POST /engines/{engine}/pause
+//	POST /engines/{engine}/resume
+//	POST /flatten                  ?coin= flattens one coin, else the whole account
+//	POST /coins/{coin}/weight      {"weight": 0.5}
+//	POST /coins/{coin}/enable
+//	POST /coins/{coin}/disable
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/itay747/hyperformance/models"
+	"github.com/itay747/hyperformance/ws"
+)
+
+// Server is the control API of one Manager and its children.
+type Server struct {
+	manager *ws.Manager
+	addr    string
+}
+
+// NewServer serves on addr: host:port, or unix:/path for a Unix socket.
+func NewServer(manager *ws.Manager, addr string) *Server {
+	return &Server{manager: manager, addr: addr}
+}
+
+// Start listens and serves until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("control listen on %s: %w", s.addr, err)
+	}
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go server.Serve(listener)
+	return nil
+}
+
+func (s *Server) listen() (net.Listener, error) {
+	if path, ok := strings.CutPrefix(s.addr, "unix:"); ok {
+		// a socket left by a previous run would fail the bind
+		os.Remove(path)
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", s.addr)
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /state", s.handleState)
+	mux.HandleFunc("POST /engines/{engine}/pause", s.handleEngine(true))
+	mux.HandleFunc("POST /engines/{engine}/resume", s.handleEngine(false))
+	mux.HandleFunc("POST /flatten", s.handleFlatten)
+	mux.HandleFunc("POST /coins/{coin}/weight", s.handleWeight)
+	mux.HandleFunc("POST /coins/{coin}/enable", s.handleCoinEnabled(true))
+	mux.HandleFunc("POST /coins/{coin}/disable", s.handleCoinEnabled(false))
+	return mux
+}
+
+// State is the GET /state response for one paste account.
+type State struct {
+	PasteAddress  string                    `json:"paste_address"`
+	CopyAddress   string                    `json:"copy_address"`
+	Ready         bool                      `json:"ready"`
+	DryRun        bool                      `json:"dry_run"`
+	Halted        string                    `json:"halted,omitempty"`
+	Engines       map[string]ws.EngineState `json:"engines"`
+	Coins         map[string]CoinState      `json:"coins"`
+	AccountValue  float64                   `json:"account_value"`
+	RiskRejected  map[ws.RiskReason]int     `json:"risk_rejected"`
+	PasteAccounts []State                   `json:"paste_accounts,omitempty"`
+}
+
+type CoinState struct {
+	Enabled     bool    `json:"enabled"`
+	Weight      float64 `json:"weight"`
+	Mid         float64 `json:"mid"`
+	CopySzi     float64 `json:"copy_szi"`
+	TargetSzi   float64 `json:"target_szi"`
+	PasteSzi    float64 `json:"paste_szi"`
+	CopyOrders  int     `json:"copy_orders"`
+	PasteOrders int     `json:"paste_orders"`
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	manager, ok := s.account(w, r)
+	if !ok {
+		return
+	}
+	state := stateOf(manager)
+	if manager == s.manager {
+		for _, child := range manager.Children {
+			state.PasteAccounts = append(state.PasteAccounts, stateOf(child))
+		}
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func stateOf(manager *ws.Manager) State {
+	halted, _ := manager.Breaker.Status()
+	state := State{
+		PasteAddress: manager.PasteAddress,
+		CopyAddress:  manager.CopyAddress,
+		Ready:        manager.IsReady(),
+		DryRun:       manager.IsDryRun(),
+		Halted:       halted,
+		Engines:      manager.EngineStates(),
+		Coins:        make(map[string]CoinState),
+		AccountValue: manager.PasteWd2.AccountValue(),
+		RiskRejected: manager.RiskGate.Rejections(),
+	}
+	copyPositions, copyOrders := positionsAndOrders(manager.CopyWd2)
+	pastePositions, pasteOrders := positionsAndOrders(manager.PasteWd2)
+	var targets map[string]models.Position
+	if manager.CopyWd2 != nil && manager.PasteWd2 != nil {
+		targets = manager.TargetPositions()
+	}
+	for coin, weight := range manager.CoinRiskMap() {
+		state.Coins[coin] = CoinState{
+			Enabled:     manager.IsEnabledCoin(coin),
+			Weight:      weight,
+			Mid:         manager.GetMidPrice(coin),
+			CopySzi:     copyPositions[coin].Szi,
+			TargetSzi:   targets[coin].Szi,
+			PasteSzi:    pastePositions[coin].Szi,
+			CopyOrders:  copyOrders[coin],
+			PasteOrders: pasteOrders[coin],
+		}
+	}
+	return state
+}
+
+func positionsAndOrders(wd2 *models.WebData2Message) (map[string]models.Position, map[string]int) {
+	orders := make(map[string]int)
+	if wd2 == nil {
+		return nil, orders
+	}
+	for _, order := range wd2.Orders() {
+		orders[order.Coin]++
+	}
+	return wd2.PositionsByCoin(), orders
+}
+
+func (s *Server) handleEngine(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := s.account(w, r)
+		if !ok {
+			return
+		}
+		reply(w, manager.SetEnginePaused(r.PathValue("engine"), paused))
+	}
+}
+
+func (s *Server) handleFlatten(w http.ResponseWriter, r *http.Request) {
+	manager, ok := s.account(w, r)
+	if !ok {
+		return
+	}
+	coin := r.URL.Query().Get("coin")
+	if _, configured := manager.CoinRiskMap()[coin]; coin != "" && !configured {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("coin %s is not configured", coin))
+		return
+	}
+	reply(w, manager.Flatten(coin))
+}
+
+func (s *Server) handleWeight(w http.ResponseWriter, r *http.Request) {
+	manager, ok := s.account(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Weight float64 `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode body: %w", err))
+		return
+	}
+	reply(w, manager.SetCoinWeight(r.PathValue("coin"), body.Weight))
+}
+
+func (s *Server) handleCoinEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		manager, ok := s.account(w, r)
+		if !ok {
+			return
+		}
+		reply(w, manager.SetCoinEnabled(r.PathValue("coin"), enabled))
+	}
+}
+
+func (s *Server) account(w http.ResponseWriter, r *http.Request) (*ws.Manager, bool) {
+	address := r.URL.Query().Get("account")
+	manager, ok := s.manager.Account(address)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown paste account %s", address))
+	}
+	return manager, ok
+}
+
+func reply(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
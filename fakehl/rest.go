@@ -0,0 +1,180 @@
+package fakehl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+)
+
+// Action is a signed request received on /exchange. Signatures are not verified.
+type Action struct {
+	Type         string
+	Raw          json.RawMessage
+	Nonce        int64
+	VaultAddress string
+}
+
+// Decode unmarshals the raw action, e.g. into hl.OrderAction or hl.CancelCloidOrderAction.
+func (a Action) Decode(v any) error {
+	return json.Unmarshal(a.Raw, v)
+}
+
+func (s *Server) SetMids(mids map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for coin, mid := range mids {
+		s.mids[coin] = mid
+	}
+}
+
+// SetUserState sets what clearinghouseState returns for user, used by ClosePosition.
+func (s *Server) SetUserState(user string, state hl.UserState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userStates[strings.ToLower(user)] = state
+}
+
+// SetOpenOrders sets what openOrders returns for user, used by CancelAllOrders.
+func (s *Server) SetOpenOrders(user string, orders []hl.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.openOrders[strings.ToLower(user)] = orders
+}
+
+// HandleExchange replaces the default /exchange responses. The returned value is
+// written back as JSON.
+func (s *Server) HandleExchange(handler func(Action) any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchangeHandler = handler
+}
+
+// Actions returns every /exchange action received so far.
+func (s *Server) Actions() []Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Action(nil), s.actions...)
+}
+
+// WaitForAction blocks until an action of actionType has been received and returns
+// the first one.
+func (s *Server) WaitForAction(ctx context.Context, actionType string) (Action, error) {
+	for {
+		for _, action := range s.Actions() {
+			if action.Type == actionType {
+				return action, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return Action{}, ctx.Err()
+		case <-s.actionAdded:
+		}
+	}
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type string `json:"type"`
+		User string `json:"user"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := strings.ToLower(req.User)
+	switch req.Type {
+	case "meta":
+		writeJSON(w, s.meta)
+	case "spotMeta":
+		writeJSON(w, hl.SpotMeta{})
+	case "allMids":
+		writeJSON(w, s.mids)
+	case "clearinghouseState":
+		writeJSON(w, s.userStates[user])
+	case "openOrders":
+		orders := s.openOrders[user]
+		if orders == nil {
+			orders = []hl.Order{}
+		}
+		writeJSON(w, orders)
+	default:
+		http.Error(w, "unsupported info type "+req.Type, http.StatusBadRequest)
+	}
+}
+
+func (s *Server) handleExchange(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action       json.RawMessage `json:"action"`
+		Nonce        int64           `json:"nonce"`
+		VaultAddress string          `json:"vaultAddress"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(req.Action, &head)
+	action := Action{Type: head.Type, Raw: req.Action, Nonce: req.Nonce, VaultAddress: req.VaultAddress}
+
+	s.mu.Lock()
+	s.actions = append(s.actions, action)
+	handler := s.exchangeHandler
+	s.mu.Unlock()
+	select {
+	case s.actionAdded <- struct{}{}:
+	default:
+	}
+
+	if handler != nil {
+		writeJSON(w, handler(action))
+		return
+	}
+	writeJSON(w, defaultExchangeResponse(action))
+}
+
+// defaultExchangeResponse rests every order and accepts every cancel and modify.
+func defaultExchangeResponse(action Action) any {
+	var body struct {
+		Orders   []json.RawMessage `json:"orders"`
+		Cancels  []json.RawMessage `json:"cancels"`
+		Modifies []json.RawMessage `json:"modifies"`
+	}
+	action.Decode(&body)
+	var statuses []any
+	switch action.Type {
+	case "order":
+		for i := range body.Orders {
+			statuses = append(statuses, map[string]any{"resting": map[string]any{"oid": i + 1}})
+		}
+	case "batchModify":
+		for i := range body.Modifies {
+			statuses = append(statuses, map[string]any{"resting": map[string]any{"oid": i + 1}})
+		}
+	case "cancel", "cancelByCloid":
+		for range body.Cancels {
+			statuses = append(statuses, "success")
+		}
+	default:
+		return map[string]any{"status": "ok", "response": map[string]any{"type": "default"}}
+	}
+	return map[string]any{
+		"status": "ok",
+		"response": map[string]any{
+			"type": action.Type,
+			"data": map[string]any{"statuses": statuses},
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,103 @@
+// Package fakehl is an in-process stand-in for the Hyperliquid API. It speaks the
+// websocket subscription protocol and answers the /info and /exchange requests made by
+// go-hyperliquid, so a Manager and its engines can run end-to-end without a network.
+package fakehl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/gorilla/websocket"
+	"github.com/itay747/hyperformance/models"
+)
+
+// Server is a scriptable fake Hyperliquid. Point a Manager at WsURL through the
+// ws_endpoint config key, and route go-hyperliquid's REST calls to it with
+// InstallTransport.
+type Server struct {
+	URL   string
+	WsURL string
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu            sync.Mutex
+	conns         map[*wsConn]struct{}
+	subscriptions []models.Subscription
+	subscribed    chan struct{}
+
+	meta       hl.Meta
+	mids       map[string]string
+	userStates map[string]hl.UserState
+	openOrders map[string][]hl.Order
+
+	actions         []Action
+	actionAdded     chan struct{}
+	exchangeHandler func(Action) any
+}
+
+// New starts a fake server whose perp universe is assets, in asset id order.
+func New(assets ...hl.Asset) *Server {
+	s := &Server{
+		conns:       make(map[*wsConn]struct{}),
+		subscribed:  make(chan struct{}, 1),
+		meta:        hl.Meta{Universe: assets},
+		mids:        make(map[string]string),
+		userStates:  make(map[string]hl.UserState),
+		openOrders:  make(map[string][]hl.Order),
+		actionAdded: make(chan struct{}, 1),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.handleWs)
+	mux.HandleFunc("/info", s.handleInfo)
+	mux.HandleFunc("/exchange", s.handleExchange)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	s.WsURL = "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/ws"
+	return s
+}
+
+// Close disconnects every websocket client and stops the server.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+// InstallTransport routes requests for the Hyperliquid REST hosts to the fake server by
+// swapping http.DefaultClient's transport, which go-hyperliquid uses. The returned func
+// restores the previous transport.
+func (s *Server) InstallTransport() (restore func()) {
+	target, _ := url.Parse(s.URL)
+	prev := http.DefaultClient.Transport
+	base := prev
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	http.DefaultClient.Transport = &rewriteTransport{target: target, base: base}
+	return func() {
+		http.DefaultClient.Transport = prev
+	}
+}
+
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Host, "hyperliquid.xyz") || strings.HasSuffix(req.URL.Host, "hyperliquid-testnet.xyz") {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = t.target.Scheme
+		req.URL.Host = t.target.Host
+		req.Host = t.target.Host
+	}
+	return t.base.RoundTrip(req)
+}
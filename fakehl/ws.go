@@ -0,0 +1,141 @@
+package fakehl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/itay747/hyperformance/models"
+)
+
+type wsConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+	subs []models.Subscription
+}
+
+func (c *wsConn) writeJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+type wsFrame struct {
+	Channel string `json:"channel"`
+	Data    any    `json:"data"`
+}
+
+func (s *Server) handleWs(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn}
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req models.SubscriptionRequest
+		if json.Unmarshal(data, &req) != nil {
+			continue
+		}
+		switch req.Method {
+		case "ping":
+			c.writeJSON(wsFrame{Channel: "pong"})
+		case "subscribe":
+			s.mu.Lock()
+			c.subs = append(c.subs, req.Subscription)
+			s.subscriptions = append(s.subscriptions, req.Subscription)
+			s.mu.Unlock()
+			select {
+			case s.subscribed <- struct{}{}:
+			default:
+			}
+			c.writeJSON(wsFrame{Channel: "subscriptionResponse", Data: req})
+		}
+	}
+}
+
+// Subscriptions returns every subscription received so far, across connections.
+func (s *Server) Subscriptions() []models.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.Subscription(nil), s.subscriptions...)
+}
+
+// WaitForSubscriptions blocks until at least n subscriptions have been received.
+func (s *Server) WaitForSubscriptions(ctx context.Context, n int) error {
+	for {
+		if len(s.Subscriptions()) >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.subscribed:
+		}
+	}
+}
+
+// Push sends {"channel": channel, "data": data} to every connection subscribed to
+// channel for user and coin. Empty user or coin match any subscription.
+func (s *Server) Push(channel, user, coin string, data any) int {
+	s.mu.Lock()
+	var targets []*wsConn
+	for c := range s.conns {
+		for _, sub := range c.subs {
+			if sub.Type != channel {
+				continue
+			}
+			if user != "" && sub.User != "" && !strings.EqualFold(sub.User, user) {
+				continue
+			}
+			if coin != "" && sub.Coin != "" && sub.Coin != coin {
+				continue
+			}
+			targets = append(targets, c)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	sent := 0
+	for _, c := range targets {
+		if c.writeJSON(wsFrame{Channel: channel, Data: data}) == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// PushWebData2 sends wd2 to the subscribers of its user.
+func (s *Server) PushWebData2(wd2 *models.WebData2Message) int {
+	return s.Push("webData2", wd2.Data.User, "", wd2.Data)
+}
+
+func (s *Server) PushOrderUpdates(user string, updates []models.OrderUpdate) int {
+	return s.Push("orderUpdates", user, "", updates)
+}
+
+// PushActiveAssetData uses the wire form, since UserAssetData expects string sizes.
+func (s *Server) PushActiveAssetData(data models.WireActiveAssetData) int {
+	return s.Push("activeAssetData", data.User, data.Coin, data)
+}
+
+func (s *Server) PushL2Book(book *models.L2BookSnapshotMessage) int {
+	return s.Push("l2Book", "", book.Data.Coin, book.Data)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistogramWrite(t *testing.T) {
+	h := NewHistogramVec("test_latency_seconds", "Test latency.", []float64{0.1, 1}, "engine")
+	h.Observe(0.05, "ioc")
+	h.Observe(0.5, "ioc")
+	h.Observe(2, "ioc")
+	h.Observe(0.1, "alo")
+
+	var b strings.Builder
+	h.f.write(&b)
+	want := `# HELP test_latency_seconds Test latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{engine="alo",le="0.1"} 1
+test_latency_seconds_bucket{engine="alo",le="1"} 1
+test_latency_seconds_bucket{engine="alo",le="+Inf"} 1
+test_latency_seconds_sum{engine="alo"} 0.1
+test_latency_seconds_count{engine="alo"} 1
+test_latency_seconds_bucket{engine="ioc",le="0.1"} 1
+test_latency_seconds_bucket{engine="ioc",le="1"} 2
+test_latency_seconds_bucket{engine="ioc",le="+Inf"} 3
+test_latency_seconds_sum{engine="ioc"} 2.55
+test_latency_seconds_count{engine="ioc"} 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("histogram output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistryWriteToRunsHooks(t *testing.T) {
+	r := NewRegistry()
+	g := &GaugeVec{r.register(&family{name: "test_depth", help: "Queue depth.", kind: "gauge", series: make(map[string]*series)})}
+	r.OnScrape(func() { g.Set(3) })
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "test_depth 3\n") {
+		t.Errorf("scrape hook value missing from:\n%s", b.String())
+	}
+}
@@ -154,28 +154,31 @@ type BotMeta struct {
 type UserFillsMessage struct {
 	Channel string `json:"channel"`
 	Data    struct {
-		IsSnapshot bool   `json:"isSnapshot"`
-		User       string `json:"user"`
-		Fills      []struct {
-			Coin          string  `json:"coin"`
-			Px            float64 `json:"px,string"`
-			Sz            float64 `json:"sz,string"`
-			Side          string  `json:"side"`
-			Time          int64   `json:"time"`
-			StartPosition string  `json:"startPosition"`
-			Dir           string  `json:"dir"`
-			ClosedPnl     float64 `json:"closedPnl,string"`
-			Hash          string  `json:"hash"`
-			Oid           int     `json:"oid"`
-			Crossed       bool    `json:"crossed"`
-			Fee           float64 `json:"fee,string"`
-			Tid           int64   `json:"tid"`
-			Cloid         string  `json:"cloid"`
-			FeeToken      string  `json:"feeToken"`
-		} `json:"fills"`
+		IsSnapshot bool       `json:"isSnapshot"`
+		User       string     `json:"user"`
+		Fills      []UserFill `json:"fills"`
 	} `json:"data"`
 }
 
+// UserFill is one fill of a userFills message. Tid is unique per fill.
+type UserFill struct {
+	Coin          string  `json:"coin"`
+	Px            float64 `json:"px,string"`
+	Sz            float64 `json:"sz,string"`
+	Side          string  `json:"side"`
+	Time          int64   `json:"time"`
+	StartPosition string  `json:"startPosition"`
+	Dir           string  `json:"dir"`
+	ClosedPnl     float64 `json:"closedPnl,string"`
+	Hash          string  `json:"hash"`
+	Oid           int     `json:"oid"`
+	Crossed       bool    `json:"crossed"`
+	Fee           float64 `json:"fee,string"`
+	Tid           int64   `json:"tid"`
+	Cloid         string  `json:"cloid"`
+	FeeToken      string  `json:"feeToken"`
+}
+
 // OrderUpdates aggregates various types of order updates.
 type OrderUpdates struct {
 	Orders        []OrderUpdate
@@ -208,6 +211,7 @@ type OrderStreams struct {
 
 // AssetDetails provides detailed information about an asset including leverage and trade limits.
 type AssetDetails struct {
+	LeverageType     string
 	LeverageValue    float64
 	MaxTradeAmounts  []float64
 	AvailableToTrade []float64
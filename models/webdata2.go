@@ -142,6 +142,30 @@ func (wd2 *WebData2Message) NewAloOrders(coinRiskMap map[string]float64) map[str
 	return newOrders
 }
 
+// ModifiedAloOrders returns the orders open on both this and the previous snapshot under
+// the same cloid whose price or size changed, as {previous, current} pairs.
+func (wd2 *WebData2Message) ModifiedAloOrders(coinRiskMap map[string]float64) map[string][2]hl.Order {
+	modifiedOrders := make(map[string][2]hl.Order)
+
+	if wd2.prev == nil {
+		return modifiedOrders
+	}
+	prevOrders := wd2.prev.OrdersByCloid()
+	for cloid, nextOrder := range wd2.OrdersByCloid() {
+		if _, ok := coinRiskMap[nextOrder.Coin]; !ok {
+			continue
+		}
+		prevOrder, exists := prevOrders[cloid]
+		if !exists {
+			continue
+		}
+		if prevOrder.LimitPx != nextOrder.LimitPx || prevOrder.OrigSz != nextOrder.OrigSz {
+			modifiedOrders[cloid] = [2]hl.Order{prevOrder, nextOrder}
+		}
+	}
+	return modifiedOrders
+}
+
 func (wd2 *WebData2Message) CancelledAloOrders(coinRiskMap map[string]float64) map[string]hl.Order {
 	cancelledOrders := make(map[string]hl.Order)
 
@@ -362,7 +386,8 @@ type AssetCtx struct {
 	ImpactPxs    []string `json:"impactPxs"`
 	DayBaseVlm   float64  `json:"dayBaseVlm,string"`
 }
-type AssetPositions []struct {
+type AssetPositions []AssetPosition
+type AssetPosition struct {
 	Type     string   `json:"type"`
 	Position Position `json:"position"`
 }
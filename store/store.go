@@ -0,0 +1,226 @@
+// Package store is a single-file, append-only journal of the engines' dedup state, so a
+// restarted bot neither duplicates ALO orders nor reuses clearinghouse times.
+//
+// Every Put and Delete appends one JSON line. Open replays the file, dropping entries
+// older than the retention and any torn last line, and rewrites it compacted. A running
+// Store compacts the same way every compactEvery, or sooner once most lines are stale.
+// Cloid links don't expire: the ALO engine deletes them once their orders have closed.
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindCreatedCloid      Kind = "created"
+	KindCanceledCloid     Kind = "canceled"
+	KindUsedPasteWd2      Kind = "paste_wd2"
+	KindUsedCopyAloCreate Kind = "copy_alo"
+	// KindCloidLink maps a copy cloid (Key) to the paste cloid mirroring it (Value).
+	KindCloidLink Kind = "link"
+)
+
+// compactMinEntries keeps small journals from being rewritten on every few Puts.
+const compactMinEntries = 4096
+
+// compactEvery is how often a running Store compacts, expiring entries past the retention.
+var compactEvery = time.Hour
+
+// Entry is one journal line. Account is the paste address the entry belongs to. A
+// Deleted entry drops the key's earlier entry.
+type Entry struct {
+	Account string `json:"a"`
+	Kind    Kind   `json:"k"`
+	Key     string `json:"key"`
+	Value   string `json:"v,omitempty"`
+	At      int64  `json:"t"`
+	Deleted bool   `json:"d,omitempty"`
+}
+
+func (e Entry) Time() time.Time {
+	return time.UnixMilli(e.At)
+}
+
+type entryKey struct {
+	account string
+	kind    Kind
+	key     string
+}
+
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	file      *os.File
+	writer    *bufio.Writer
+	live      map[entryKey]Entry
+	journaled int
+	compacted time.Time
+	closed    bool
+}
+
+// Open loads the journal at path, creating it if needed, and compacts it.
+func Open(path string, retention time.Duration) (*Store, error) {
+	s := &Store{
+		path:      path,
+		retention: retention,
+		live:      make(map[entryKey]Entry),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open state %s: %w", s.path, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		// A crash can leave the last line half written
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		key := entryKey{entry.Account, entry.Kind, entry.Key}
+		if entry.Deleted {
+			delete(s.live, key)
+			continue
+		}
+		s.live[key] = entry
+	}
+	return scanner.Err()
+}
+
+// Put journals key (and value) for account, replacing any previous entry for the key.
+func (s *Store) Put(account string, kind Kind, key, value string) error {
+	return s.append(Entry{Account: account, Kind: kind, Key: key, Value: value, At: time.Now().UnixMilli()})
+}
+
+// Delete journals that key no longer has an entry for account.
+func (s *Store) Delete(account string, kind Kind, key string) error {
+	return s.append(Entry{Account: account, Kind: kind, Key: key, At: time.Now().UnixMilli(), Deleted: true})
+}
+
+func (s *Store) append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("store closed")
+	}
+	key := entryKey{entry.Account, entry.Kind, entry.Key}
+	if entry.Deleted {
+		delete(s.live, key)
+	} else {
+		s.live[key] = entry
+	}
+	s.writer.Write(line)
+	s.writer.WriteByte('\n')
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("write state %s: %w", s.path, err)
+	}
+	s.journaled++
+	if s.journaled > compactMinEntries && s.journaled > 2*len(s.live) || time.Since(s.compacted) >= compactEvery {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+// Entries returns the live entries of kind for account.
+func (s *Store) Entries(account string, kind Kind) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []Entry
+	for key, entry := range s.live {
+		if key.account == account && key.kind == kind {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// compactLocked drops expired entries and rewrites the journal with only the live ones.
+func (s *Store) compactLocked() error {
+	s.compacted = time.Now()
+	if s.retention > 0 {
+		cutoff := time.Now().Add(-s.retention).UnixMilli()
+		for key, entry := range s.live {
+			if entry.At < cutoff && entry.Kind != KindCloidLink {
+				delete(s.live, key)
+			}
+		}
+	}
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("compact state %s: %w", s.path, err)
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range s.live {
+		encoder.Encode(entry)
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact state %s: %w", s.path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact state %s: %w", s.path, err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("compact state %s: %w", s.path, err)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open state %s: %w", s.path, err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	s.journaled = len(s.live)
+	return nil
+}
+
+// Close syncs and closes the journal. Closing twice is a no-op.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.writer.Flush()
+	if syncErr := s.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
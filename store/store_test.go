@@ -0,0 +1,174 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func lineCount(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func keys(entries []Entry) []string {
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestOpenCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	s, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		s.Put("0xpaste", KindCloidLink, "0xcopy", "0xlinked")
+	}
+	s.Put("0xpaste", KindCreatedCloid, "a", "")
+	s.Put("0xpaste", KindCreatedCloid, "b", "")
+	s.Delete("0xpaste", KindCreatedCloid, "a")
+	s.Put("0xother", KindCreatedCloid, "c", "")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := lineCount(t, path); n != 14 {
+		t.Fatalf("journal has %d lines before compaction, want 14", n)
+	}
+
+	s, err = Open(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if n := lineCount(t, path); n != 3 {
+		t.Errorf("journal has %d lines after compaction, want 3", n)
+	}
+	if got := keys(s.Entries("0xpaste", KindCreatedCloid)); len(got) != 1 || got[0] != "b" {
+		t.Errorf("created cloids = %v, want [b]", got)
+	}
+	links := s.Entries("0xpaste", KindCloidLink)
+	if len(links) != 1 || links[0].Value != "0xlinked" {
+		t.Errorf("links = %+v, want one to 0xlinked", links)
+	}
+	if got := keys(s.Entries("0xother", KindCreatedCloid)); len(got) != 1 || got[0] != "c" {
+		t.Errorf("other account's created cloids = %v, want [c]", got)
+	}
+}
+
+func TestOpenDropsExpiredAndTornEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	old := time.Now().Add(-2 * time.Hour).UnixMilli()
+	fresh := time.Now().UnixMilli()
+	journal := `{"a":"0xpaste","k":"created","key":"old","t":` + strconv.FormatInt(old, 10) + `}
+{"a":"0xpaste","k":"created","key":"fresh","t":` + strconv.FormatInt(fresh, 10) + `}
+{"a":"0xpaste","k":"created","key":"torn","t":`
+	if err := os.WriteFile(path, []byte(journal), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := keys(s.Entries("0xpaste", KindCreatedCloid)); len(got) != 1 || got[0] != "fresh" {
+		t.Errorf("created cloids = %v, want [fresh]", got)
+	}
+	if n := lineCount(t, path); n != 1 {
+		t.Errorf("journal has %d lines after compaction, want 1", n)
+	}
+}
+
+func TestCompactsWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	s, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 0; i <= 2*compactMinEntries; i++ {
+		if err := s.Put("0xpaste", KindUsedPasteWd2, "1", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := lineCount(t, path); n > compactMinEntries {
+		t.Errorf("journal has %d lines, want it compacted below %d", n, compactMinEntries)
+	}
+	if got := s.Entries("0xpaste", KindUsedPasteWd2); len(got) != 1 {
+		t.Errorf("entries = %+v, want one", got)
+	}
+}
+
+func TestClosedStoreRejectsPuts(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "state.jsonl"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+	if err := s.Put("0xpaste", KindCreatedCloid, "a", ""); err == nil {
+		t.Error("Put after Close succeeded")
+	}
+}
+
+func TestCompactsByAgeWhileRunning(t *testing.T) {
+	defer func(every time.Duration) { compactEvery = every }(compactEvery)
+	compactEvery = time.Hour
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	s, err := Open(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// every key is new, so the journal never holds twice the live entries
+	for i := 0; i < 100; i++ {
+		s.Put("0xpaste", KindUsedPasteWd2, strconv.Itoa(i), "")
+	}
+	s.Put("0xpaste", KindCloidLink, "0xcopy", "0xlinked")
+	time.Sleep(100 * time.Millisecond)
+	compactEvery = 0
+	s.Put("0xpaste", KindUsedPasteWd2, "fresh", "")
+
+	if got := keys(s.Entries("0xpaste", KindUsedPasteWd2)); len(got) != 1 || got[0] != "fresh" {
+		t.Errorf("entries after compaction = %v, want [fresh]", got)
+	}
+	if n := lineCount(t, path); n != 2 {
+		t.Errorf("journal has %d lines after compaction, want the fresh entry and the link", n)
+	}
+}
+
+func TestLinksOutliveTheRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	old := strconv.FormatInt(time.Now().Add(-48*time.Hour).UnixMilli(), 10)
+	journal := `{"a":"0xpaste","k":"link","key":"0xcopy","v":"0xlinked","t":` + old + `}
+{"a":"0xpaste","k":"created","key":"0xcopy","t":` + old + `}
+`
+	if err := os.WriteFile(path, []byte(journal), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if links := s.Entries("0xpaste", KindCloidLink); len(links) != 1 || links[0].Value != "0xlinked" {
+		t.Errorf("links = %+v, want the day old link kept", links)
+	}
+	if created := s.Entries("0xpaste", KindCreatedCloid); len(created) != 0 {
+		t.Errorf("created cloids = %+v, want the expired one dropped", created)
+	}
+}
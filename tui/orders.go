@@ -9,11 +9,12 @@ import (
 
 	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/itay747/hyperformance/cloid"
 	"github.com/itay747/hyperformance/ws"
 )
 
 type OrdersRenderer interface {
-	RenderPane(isCopy bool, orders map[int]hl.Order, copyOrders map[int]hl.Order, width int) string
+	RenderPane(isCopy bool, orders map[uint64]hl.Order, copyOrders map[uint64]hl.Order, width int) string
 }
 
 type OrderColumnSpec struct {
@@ -109,6 +110,9 @@ func NewOrdersRenderer(manager *ws.Manager) *DefaultOrdersRenderer {
 				Ratio: 0.2,
 				Align: lipgloss.Right,
 				ValueFn: func(o hl.Order, key bool) string {
+					if _, ok := cloid.Decode(o.Cloid); ok {
+						return cloid.Label(o.Cloid)
+					}
 					val, _ := hl.HexToInt(o.Cloid)
 					return fmt.Sprintf("%v", val)
 				},
@@ -117,7 +121,7 @@ func NewOrdersRenderer(manager *ws.Manager) *DefaultOrdersRenderer {
 	}
 }
 
-func (r *DefaultOrdersRenderer) RenderPane(isCopy bool, orders map[int]hl.Order, copyOrders map[int]hl.Order, width int) string {
+func (r *DefaultOrdersRenderer) RenderPane(isCopy bool, orders map[uint64]hl.Order, copyOrders map[uint64]hl.Order, width int) string {
 	if width < r.minWidth {
 		width = r.minWidth
 	}
@@ -153,7 +157,7 @@ func (r *DefaultOrdersRenderer) renderHeader(totalWidth int) string {
 	return lipgloss.JoinHorizontal(lipgloss.Top, cells...)
 }
 
-func (r *DefaultOrdersRenderer) renderRows(isCopy bool, orders map[int]hl.Order, copyOrders map[int]hl.Order, totalWidth int) string {
+func (r *DefaultOrdersRenderer) renderRows(isCopy bool, orders map[uint64]hl.Order, copyOrders map[uint64]hl.Order, totalWidth int) string {
 	acc := 0
 	colWidths := make([]int, len(r.columns))
 
@@ -174,13 +178,13 @@ func (r *DefaultOrdersRenderer) renderRows(isCopy bool, orders map[int]hl.Order,
 		cloids = append(cloids, order.Cloid)
 	}
 	sort.Slice(cloids, func(i, j int) bool {
-		return CloidFull(cloids[i]) < CloidFull(cloids[j])
+		return cloid.Low64(cloids[i]) < cloid.Low64(cloids[j])
 	})
 
 	var order hl.Order
 	var lines []string
-	for _, cloid := range cloids {
-		order = orders[CloidTruncated(cloid)]
+	for _, orderCloid := range cloids {
+		order = orders[cloid.Low64(orderCloid)]
 		address := r.manager.CopyAddress
 		if !isCopy {
 			address = r.manager.PasteAddress
@@ -210,7 +214,7 @@ func (r *DefaultOrdersRenderer) renderRows(isCopy bool, orders map[int]hl.Order,
 				Align(col.Align + 3).
 				Width(colWidths[i])
 			if !isCopy {
-				if _, ok := copyOrders[CloidTruncated(order.Cloid)]; !ok {
+				if _, ok := copyOrders[cloid.Low64(order.Cloid)]; !ok {
 					cell = cell.Reverse(true)
 				}
 			}
@@ -63,7 +63,7 @@ func NewPositionsRenderer(manager *ws.Manager) *DefaultPositionsRenderer {
 					// If this is the "PASTE" pane, append italic virtual leverage
 					leverage := float64(position.Leverage.Value)
 					if strings.HasPrefix(strings.ToUpper(positionKey), "PASTE:") {
-						leverage *= manager.CoinRiskMap[position.Coin]
+						leverage *= manager.CoinRiskMap()[position.Coin]
 					}
 					leverageStr := fmt.Sprintf("%vx %s", int(leverage), position.Coin)
 					return leverageStr
@@ -189,6 +189,17 @@ func NewPositionsRenderer(manager *ws.Manager) *DefaultPositionsRenderer {
 					return "$" + hl.PriceToWire(position.EntryPx, 6, decimals)
 				},
 			},
+			{
+				Title: "Sizing",
+				Ratio: 0.125,
+				Align: lipgloss.Left,
+				ColorFn: func(_ models.Position) lipgloss.Color {
+					return lipgloss.Color("244")
+				},
+				ValueFn: func(position models.Position, _ string) string {
+					return manager.SizingLabel(position.Coin)
+				},
+			},
 			{
 				Title: "Mid Px",
 				Ratio: 0.125,
@@ -324,7 +335,7 @@ func (renderer *DefaultPositionsRenderer) renderRows(
 	// 		eng.canceledCloids[cloid] = true
 	// 	}
 	var rows []string
-	for _, coin := range renderer.manager.AllowedSymbols {
+	for _, coin := range renderer.manager.AllowedSymbols() {
 		positionKey := fmt.Sprintf("%s:%s", paneLabel, coin)
 		position, ok := positionsByCoin[coin]
 
@@ -3,13 +3,13 @@ package tui
 import (
 	"context"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
 	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/itay747/hyperformance/cloid"
 	"github.com/itay747/hyperformance/models"
 	"github.com/itay747/hyperformance/ws"
 )
@@ -174,6 +174,12 @@ func (tui *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if typed.String() == "q" || typed.String() == "ctrl+c" {
 			return tui, tea.Quit
 		}
+		switch typed.String() {
+		case "k":
+			tui.manager.TripAll("operator kill switch")
+		case "r":
+			tui.manager.ResumeAll()
+		}
 	}
 	return tui, nil
 }
@@ -182,7 +188,17 @@ func (tui *TUIModel) View() string {
 	if !tui.manager.IsReady() {
 		return "Waiting for data..."
 	}
-	titleBar := titleBarStyle.Render(" Hyperformance Printer v0.0.4 ")
+	title := " Hyperformance Printer v0.0.4 "
+	if tui.manager.IsDryRun() {
+		title += fmt.Sprintf("[DRY RUN fees $%.2f] ", tui.manager.Paper.Fees())
+	}
+	if reason, _ := tui.manager.Breaker.Status(); reason != "" {
+		title += fmt.Sprintf("[HALTED %s, r to resume] ", reason)
+	}
+	if rejections := tui.manager.RiskGate.Summary(); rejections != "" {
+		title += fmt.Sprintf("[RISK %s] ", rejections)
+	}
+	titleBar := titleBarStyle.Render(title)
 	statusBar := tui.renderStatusBar()
 	titleHeight := lipgloss.Height(titleBar)
 	statusHeight := lipgloss.Height(statusBar)
@@ -385,34 +401,13 @@ func filterPositionsByAllowedSymbols(positions map[string]models.Position, mgr *
 	}
 	return filteredPositions
 }
-func CloidFull(cloid string) int {
-	cloidValue, _ := hl.HexToInt(cloid)
-	return int(cloidValue.Int64())
-}
-func CloidTruncated(cloid string) int {
-	cloidValue := CloidFull(cloid)
-	numberStr := strconv.Itoa(cloidValue)
-	if len(numberStr) < 2 {
-		return 0
-	}
-	firstChar := numberStr[0]
-	numberStr = numberStr[1:]
-	numberStr = strings.TrimLeft(numberStr, "0")
-	if numberStr == "" {
-		return 0
-	}
-	numberStr = string(firstChar) + numberStr
-	result, err := strconv.Atoi(numberStr)
-	if err != nil {
-		return 0
-	}
-	return result
-}
 
-func filterOrdersByAllowedSymbols(orders map[string]hl.Order, mgr *ws.Manager) map[int]hl.Order {
-	filtered := make(map[int]hl.Order)
-	for cloid, order := range orders {
-		cloidValue := CloidTruncated(cloid)
+// filterOrdersByAllowedSymbols keys the orders on enabled coins by cloid.Low64, so a
+// paste ALO order and the copy order it mirrors share a key.
+func filterOrdersByAllowedSymbols(orders map[string]hl.Order, mgr *ws.Manager) map[uint64]hl.Order {
+	filtered := make(map[uint64]hl.Order)
+	for orderCloid, order := range orders {
+		cloidValue := cloid.Low64(orderCloid)
 		if dupedOrder, ok := filtered[cloidValue]; ok {
 			if order.Coin == dupedOrder.Coin {
 				msg := fmt.Sprintf("TUI meltdown attempting to render dupe cloid orders: \n%#+v\n%#+v", dupedOrder, order)
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/models"
+)
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// NewJSONLogger writes one JSON object per line to out, for running without the TUI.
+// Styling is stripped, the component is taken from a leading [Component], and the coin,
+// cloid, side, size and px are those passed to LogFields.
+func NewJSONLogger(out io.Writer) *DualLogger {
+	dl := NewDualLogger(nil)
+	dl.out = out
+	return dl
+}
+
+// Write logs p at info level, so the standard log package can write through dl.
+func (dl *DualLogger) Write(p []byte) (int, error) {
+	dl.LogInfo(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// Fields describe the order or fill a log line is about. Zero fields are left out.
+type Fields struct {
+	Coin  string
+	Cloid string
+	// Side is BUY or SELL.
+	Side string
+	Size float64
+	Px   float64
+}
+
+// OrderFields describes an order.
+func OrderFields(o hl.Order) Fields {
+	return Fields{Coin: o.Coin, Cloid: o.Cloid, Side: sideWord(o.Side == "B"), Size: o.Sz, Px: o.LimitPx}
+}
+
+// RequestFields describes an order request.
+func RequestFields(r hl.OrderRequest) Fields {
+	return Fields{Coin: r.Coin, Cloid: r.Cloid, Side: sideWord(r.IsBuy), Size: r.Sz, Px: r.LimitPx}
+}
+
+// FillFields describes a fill.
+func FillFields(f models.UserFill) Fields {
+	return Fields{Coin: f.Coin, Cloid: f.Cloid, Side: sideWord(f.Side == "B"), Size: f.Sz, Px: f.Px}
+}
+
+func sideWord(isBuy bool) string {
+	if isBuy {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+// LogFields logs message at level (debug, info, warn or error) with the order or fill it
+// is about. The JSON logger writes fields as keys of their own; the TUI shows the message.
+func (dl *DualLogger) LogFields(level, message string, fields Fields) {
+	if dl.out != nil {
+		dl.writeJSON(level, message, fields)
+		return
+	}
+	switch level {
+	case "debug":
+		dl.LogDebug(message)
+	case "warn":
+		dl.LogWarn(message)
+	case "error":
+		dl.LogError(message)
+	default:
+		dl.LogInfo(message)
+	}
+}
+
+type jsonLogEntry struct {
+	Level     string   `json:"level"`
+	Ts        string   `json:"ts"`
+	Component string   `json:"component,omitempty"`
+	Coin      string   `json:"coin,omitempty"`
+	Cloid     string   `json:"cloid,omitempty"`
+	Side      string   `json:"side,omitempty"`
+	Size      *float64 `json:"size,omitempty"`
+	Px        *float64 `json:"px,omitempty"`
+	Msg       string   `json:"msg"`
+}
+
+func (dl *DualLogger) writeJSON(level, message string, fields Fields) {
+	message = strings.TrimSpace(ansiEscape.ReplaceAllString(message, ""))
+	entry := jsonLogEntry{
+		Level: level,
+		Ts:    time.Now().UTC().Format(time.RFC3339Nano),
+		Coin:  fields.Coin,
+		Cloid: fields.Cloid,
+		Side:  fields.Side,
+		Msg:   message,
+	}
+	if strings.HasPrefix(message, "[") {
+		if end := strings.Index(message, "]"); end > 0 {
+			entry.Component = message[1:end]
+		}
+	}
+	if fields.Size != 0 {
+		entry.Size = &fields.Size
+	}
+	if fields.Px != 0 {
+		entry.Px = &fields.Px
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	encoder := json.NewEncoder(dl.out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(entry)
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	r, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		path:        "dddddd\n",
+		path + ".1": "cccccc\n",
+		path + ".2": "bbbbbb\n",
+	}
+	for file, content := range want {
+		if got := readFile(t, file); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want the oldest backup dropped", filepath.Base(path))
+	}
+}
+
+func TestRotatingFileAppendsAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	if err := os.WriteFile(path, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the existing 7 bytes count towards maxBytes
+	r.Write([]byte("after\n"))
+	r.Close()
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("bot.log = %q, want %q", got, "after\n")
+	}
+	if got := readFile(t, path+".1"); got != "before\n" {
+		t.Errorf("bot.log.1 = %q, want %q", got, "before\n")
+	}
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	r, err := NewRotatingFile(path, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("one\n"))
+	r.Write([]byte("two\n"))
+	r.Close()
+	if got := readFile(t, path); got != "two\n" {
+		t.Errorf("bot.log = %q, want %q", got, "two\n")
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("bot.log.1 exists without backups")
+	}
+}
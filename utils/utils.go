@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"strings"
 	"sync"
@@ -12,8 +13,11 @@ import (
 	"github.com/itay747/hyperformance/models"
 )
 
+// DualLogger styles log lines for the TUI's log channel, or writes them as JSON lines
+// when made with NewJSONLogger.
 type DualLogger struct {
 	logCh            chan string
+	out              io.Writer
 	mu               sync.Mutex
 	infoStyle        lipgloss.Style
 	debugStyle       lipgloss.Style
@@ -62,6 +66,10 @@ func NewDualLogger(ch chan string) *DualLogger {
 }
 
 func (dl *DualLogger) LogInfo(message string) {
+	if dl.out != nil {
+		dl.writeJSON("info", message, Fields{})
+		return
+	}
 	stamp := time.Now().Format("15:04:05.000")
 	prefix := fmt.Sprintf("[Info] [%s] ", stamp)
 	line := dl.infoStyle.Render(prefix) + message
@@ -75,6 +83,10 @@ func (dl *DualLogger) LogInfof(format string, args ...interface{}) {
 }
 
 func (dl *DualLogger) LogDebug(m string) {
+	if dl.out != nil {
+		dl.writeJSON("debug", m, Fields{})
+		return
+	}
 	line := dl.debugStyle.Render("[DEBUG] ") + m
 	if dl.logCh != nil {
 		dl.logCh <- line
@@ -86,6 +98,10 @@ func (dl *DualLogger) LogDebugf(format string, args ...interface{}) {
 }
 
 func (dl *DualLogger) LogWarn(m string) {
+	if dl.out != nil {
+		dl.writeJSON("warn", m, Fields{})
+		return
+	}
 	line := dl.warningStyle.Render("[WARN] ") + m
 	if dl.logCh != nil {
 		dl.logCh <- line
@@ -97,6 +113,10 @@ func (dl *DualLogger) LogWarnf(format string, args ...interface{}) {
 }
 
 func (dl *DualLogger) LogError(m string) {
+	if dl.out != nil {
+		dl.writeJSON("error", m, Fields{})
+		return
+	}
 	line := dl.errorStyle.Render("[ERROR] ") + m
 	if dl.logCh != nil {
 		dl.logCh <- line
@@ -195,9 +215,9 @@ func (dl *DualLogger) FormatOrder(o hl.Order) string {
 }
 
 func (dl *DualLogger) FormatOrderRequest(r hl.OrderRequest) string {
-	lbl := "SHORT"
+	lbl := "A"
 	if r.IsBuy {
-		lbl = "LONG"
+		lbl = "B"
 	}
 	side := dl.styleSide(lbl, r.ReduceOnly)
 	ot := dl.styleOrderType(r.OrderType.Limit.Tif)
@@ -314,23 +334,7 @@ func (dl *DualLogger) FormatPositionList(list []hl.AssetPosition, title string)
 	return b.String()
 }
 
-func (dl *DualLogger) FormatFill(f struct {
-	Coin          string  `json:"coin"`
-	Px            float64 `json:"px,string"`
-	Sz            float64 `json:"sz,string"`
-	Side          string  `json:"side"`
-	Time          int64   `json:"time"`
-	StartPosition string  `json:"startPosition"`
-	Dir           string  `json:"dir"`
-	ClosedPnl     float64 `json:"closedPnl,string"`
-	Hash          string  `json:"hash"`
-	Oid           int     `json:"oid"`
-	Crossed       bool    `json:"crossed"`
-	Fee           float64 `json:"fee,string"`
-	Tid           int64   `json:"tid"`
-	Cloid         string  `json:"cloid"`
-	FeeToken      string  `json:"feeToken"`
-}) string {
+func (dl *DualLogger) FormatFill(f models.UserFill) string {
 	side := dl.styleSide(f.Side, false)
 	px := dl.stylePrice(f.Px)
 	sz := dl.styleSize(f.Sz)
@@ -354,23 +358,7 @@ func (dl *DualLogger) FormatFill(f struct {
 	)
 }
 
-func (dl *DualLogger) FormatFillList(fills []struct {
-	Coin          string  `json:"coin"`
-	Px            float64 `json:"px,string"`
-	Sz            float64 `json:"sz,string"`
-	Side          string  `json:"side"`
-	Time          int64   `json:"time"`
-	StartPosition string  `json:"startPosition"`
-	Dir           string  `json:"dir"`
-	ClosedPnl     float64 `json:"closedPnl,string"`
-	Hash          string  `json:"hash"`
-	Oid           int     `json:"oid"`
-	Crossed       bool    `json:"crossed"`
-	Fee           float64 `json:"fee,string"`
-	Tid           int64   `json:"tid"`
-	Cloid         string  `json:"cloid"`
-	FeeToken      string  `json:"feeToken"`
-}, title string) string {
+func (dl *DualLogger) FormatFillList(fills []models.UserFill, title string) string {
 	if len(fills) == 0 {
 		return fmt.Sprintf("\n%s\n   %s\n",
 			dl.titleStyle.Render("=== "+title+" ==="),
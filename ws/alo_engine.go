@@ -6,29 +6,52 @@ import (
 	"time"
 
 	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/config"
 	"github.com/itay747/hyperformance/models"
+	"github.com/itay747/hyperformance/store"
 )
 
 type OrdersByCloidMap map[string]hl.Order
 
 type AloEngine struct {
-	mu              sync.Mutex
-	manager         *Manager
-	enabled         bool
-	createdCloids   map[string]bool
-	canceledCloids  map[string]bool
+	mu             sync.Mutex
+	manager        *Manager
+	enabled        bool
+	createdCloids  map[string]time.Time
+	canceledCloids map[string]time.Time
+	// pasteCloids maps a copy cloid to the paste cloid mirroring it, and copyCloids back.
+	pasteCloids map[string]string
+	copyCloids  map[string]string
+	// crossing holds copy orders that would have crossed the paste book, retried on
+	// the next book of their coin.
+	crossing map[string]hl.Order
+	// crossingModifies holds modifies, by paste cloid, whose new price would have crossed
+	// the paste book, reissued like crossing.
+	crossingModifies map[string][2]hl.Order
+	// retry holds copy cloids whose creates were dropped before reaching the exchange,
+	// placed again on the next reconcile if still open.
+	retry map[string]bool
+	// resync makes the next reconcile diff every open copy ALO order against the paste
+	// book instead of the previous snapshot, after a halt or pause left it unmirrored.
+	resync          bool
 	copyOpenOrders  map[string]hl.Order
 	pasteOpenOrders map[string]hl.Order
 }
 
 func NewAloEngine(ctx context.Context, m *Manager, enabled bool) *AloEngine {
 	return &AloEngine{
-		manager:         m,
-		enabled:         enabled,
-		copyOpenOrders:  make(map[string]hl.Order),
-		pasteOpenOrders: make(map[string]hl.Order),
-		createdCloids:   make(map[string]bool),
-		canceledCloids:  make(map[string]bool),
+		manager:          m,
+		enabled:          enabled,
+		copyOpenOrders:   make(map[string]hl.Order),
+		pasteOpenOrders:  make(map[string]hl.Order),
+		createdCloids:    make(map[string]time.Time),
+		canceledCloids:   make(map[string]time.Time),
+		pasteCloids:      make(map[string]string),
+		copyCloids:       make(map[string]string),
+		crossing:         make(map[string]hl.Order),
+		crossingModifies: make(map[string][2]hl.Order),
+		retry:            make(map[string]bool),
 	}
 }
 
@@ -41,14 +64,27 @@ func (engine *AloEngine) Start(ctx context.Context,
 			select {
 			case <-ctx.Done():
 				return
-			case <-CopyWd2Chan:
+			case wd2 := <-CopyWd2Chan:
+				// ALO orders are only mirrored from the primary copy account
+				if wd2.Data.User != engine.manager.CopyAddress {
+					continue
+				}
 				if engine.pasteOpenOrders != nil {
 					engine.HandleAloReconcile()
 				}
 			case wd2 := <-PasteWd2Chan:
+				if engine.pasteOpenOrders == nil && engine.enabled && engine.manager.Config.StartupPolicy == config.StartupAdopt {
+					// adoption matches paste orders placed before the cloid codec to
+					// copy orders
+					if engine.manager.CopyWd2 == nil {
+						continue
+					}
+					engine.adoptPasteOrders(wd2)
+				}
 				engine.pasteOpenOrders = wd2.OrdersByCloid()
 
-			case <-l2Book:
+			case book := <-l2Book:
+				engine.retryCrossing(book.Coin())
 
 			}
 
@@ -56,46 +92,212 @@ func (engine *AloEngine) Start(ctx context.Context,
 	}()
 }
 func (engine *AloEngine) HandleAloReconcile() {
-	if !engine.enabled {
+	if !engine.enabled || engine.manager.Halted() || engine.manager.EnginePaused(EngineAlo) {
+		return
+	}
+	blockTime := engine.manager.CopyWd2.ClearinghouseTime().UnixMilli()
+	if _, used := engine.manager.UsedCopyAloCreates[blockTime]; used {
 		return
 	}
-	orders, cancels := engine.RunAloReconcile()
-	blockTime := engine.manager.CopyWd2.ClearinghouseTime()
+	orders, modifies, cancels := engine.RunAloReconcile()
 
 	if len(orders) > 0 {
 		go engine.processNewAloOrders(orders)
 	}
+	if len(modifies) > 0 {
+		go engine.processAloModifications(modifies)
+	}
 	if len(cancels) > 0 {
 		go engine.processCancelRequests(cancels)
 	}
-	engine.manager.UsedCopyAloCreates[blockTime.Unix()] = time.Now()
+	markUsed(engine.manager.UsedCopyAloCreates, blockTime, engine.manager.stateRetention)
+	engine.manager.journalTime(store.KindUsedCopyAloCreate, blockTime)
+
+}
 
+// Resync makes the next reconcile mirror every open copy ALO order whose paste order
+// isn't open, and move those whose price differs. Called when the engine resumes.
+func (engine *AloEngine) Resync() {
+	engine.mu.Lock()
+	engine.resync = true
+	engine.mu.Unlock()
+}
+
+// deferCrossing parks an order that would cross the book until retryCrossing.
+func (engine *AloEngine) deferCrossing(cloid string, order hl.Order) {
+	engine.mu.Lock()
+	engine.crossing[cloid] = order
+	engine.mu.Unlock()
+}
+
+// deferCrossingModify parks a modify whose new price would cross the book until
+// retryCrossing.
+func (engine *AloEngine) deferCrossingModify(pasteCloid string, pair [2]hl.Order) {
+	engine.mu.Lock()
+	engine.crossingModifies[pasteCloid] = pair
+	engine.mu.Unlock()
 }
 
-// , toCancelOid map[int64]hl.Order
-func (engine *AloEngine) RunAloReconcile() (toCreateRaw, toCancelCloid map[string]hl.Order) {
+// retryCrossing places the parked orders on coin that no longer cross and reissues the
+// parked modifies, and drops those the copy account no longer has open.
+func (engine *AloEngine) retryCrossing(coin string) {
+	if !engine.enabled || engine.manager.CopyWd2 == nil {
+		return
+	}
+	engine.mu.Lock()
+	if len(engine.crossing) == 0 && len(engine.crossingModifies) == 0 {
+		engine.mu.Unlock()
+		return
+	}
+	copyOpenOrders := engine.manager.CopyWd2.OrdersByCloid()
+	modifies := make(map[string][2]hl.Order)
+	for pasteCloid, pair := range engine.crossingModifies {
+		order := pair[1]
+		if order.Coin != coin {
+			continue
+		}
+		if _, open := copyOpenOrders[order.Cloid]; !open {
+			delete(engine.crossingModifies, pasteCloid)
+			continue
+		}
+		if !engine.manager.Books.WouldCross(coin, order.Side == "B", order.LimitPx) {
+			delete(engine.crossingModifies, pasteCloid)
+			modifies[pasteCloid] = pair
+		}
+	}
+	retry := make(map[string]hl.Order)
+	for cloid, order := range engine.crossing {
+		if order.Coin != coin {
+			continue
+		}
+		if _, open := copyOpenOrders[cloid]; !open {
+			delete(engine.crossing, cloid)
+			continue
+		}
+		if !engine.manager.Books.WouldCross(coin, order.Side == "B", order.LimitPx) {
+			delete(engine.crossing, cloid)
+			retry[cloid] = order
+		}
+	}
+	engine.mu.Unlock()
+	if len(retry) > 0 {
+		go engine.processNewAloOrders(retry)
+	}
+	if len(modifies) > 0 {
+		go engine.processAloModifications(modifies)
+	}
+}
+
+// RunAloReconcile diffs the copy ALO orders against the previous snapshot, plus the
+// creates queued for retry, or against the paste book after Resync. Creates are keyed by
+// copy cloid, modifies and cancels by paste cloid. Orders the leader moved under the same
+// cloid are modified on paste when their mirror is open, and replace the parked order
+// when it still waits for the book. Only paste orders this bot's ALO engine minted or
+// linked are canceled for missing on the copy side; other bots' orders are left alone.
+func (engine *AloEngine) RunAloReconcile() (toCreateRaw map[string]hl.Order, toModify map[string][2]hl.Order, toCancelCloid map[string]hl.Order) {
 	engine.mu.Lock()
 	defer engine.mu.Unlock()
 
-	toCreateRaw = engine.manager.CopyWd2.NewAloOrders(engine.manager.CoinRiskMap)
+	resync := engine.resync
+	engine.resync = false
+	toModify = make(map[string][2]hl.Order)
+	if resync {
+		toCreateRaw = engine.unmirroredLocked(toModify)
+	} else {
+		toCreateRaw = engine.manager.CopyWd2.NewAloOrders(engine.manager.CoinRiskMap())
+	}
+	if len(engine.retry) > 0 {
+		copyOpenOrders := engine.manager.CopyWd2.OrdersByCloid()
+		for copyCloid := range engine.retry {
+			if order, open := copyOpenOrders[copyCloid]; open {
+				toCreateRaw[copyCloid] = order
+			}
+			delete(engine.retry, copyCloid)
+		}
+	}
 	toCreateFinal := make(map[string]hl.Order)
-	for cloid, order := range toCreateRaw {
-		if _, ok := engine.createdCloids[cloid]; !ok {
-			toCreateFinal[cloid] = order
-			engine.createdCloids[cloid] = true
+	for copyCloid, order := range toCreateRaw {
+		if _, ok := engine.createdCloids[copyCloid]; ok && !resync {
+			continue
+		}
+		if _, open := engine.pasteOpenOrders[engine.pasteCloidLocked(copyCloid)]; open {
+			continue
 		}
+		toCreateFinal[copyCloid] = order
+		markUsed(engine.createdCloids, copyCloid, engine.manager.stateRetention)
+		engine.manager.journal(store.KindCreatedCloid, copyCloid, "")
 	}
+	for copyCloid, pair := range engine.manager.CopyWd2.ModifiedAloOrders(engine.manager.CoinRiskMap()) {
+		if _, parked := engine.crossing[copyCloid]; parked {
+			engine.crossing[copyCloid] = pair[1]
+			continue
+		}
+		pasteCloid := engine.pasteCloidLocked(copyCloid)
+		// a newer modify replaces one still waiting for the book
+		delete(engine.crossingModifies, pasteCloid)
+		if _, done := engine.canceledCloids[pasteCloid]; done {
+			continue
+		}
+		if _, open := engine.pasteOpenOrders[pasteCloid]; open {
+			toModify[pasteCloid] = pair
+		}
+	}
+	toCancel := make(map[string]hl.Order)
 	// orders missing copy prev, next should cancel cloids for paste
-	toCancel := engine.manager.CopyWd2.CancelledAloOrders(engine.manager.CoinRiskMap)
+	for copyCloid, order := range engine.manager.CopyWd2.CancelledAloOrders(engine.manager.CoinRiskMap()) {
+		toCancel[engine.pasteCloidLocked(copyCloid)] = order
+	}
 	// orders missing from copy on paste should cancel
-	for _, openPasteOrder := range engine.manager.PasteWd2.OrdersByCloid() {
-		copyOpenOrders := engine.manager.CopyWd2.OrdersByCloid()
-		if _, ok := copyOpenOrders[openPasteOrder.Cloid]; !ok {
-			toCancel[openPasteOrder.Cloid] = openPasteOrder
+	mirrored := make(map[string]bool)
+	for copyCloid := range engine.manager.CopyWd2.OrdersByCloid() {
+		mirrored[engine.pasteCloidLocked(copyCloid)] = true
+	}
+	for pasteCloid, openPasteOrder := range engine.manager.PasteWd2.OrdersByCloid() {
+		if _, linked := engine.copyCloids[pasteCloid]; !linked && !engine.manager.OwnsCloid(pasteCloid, cloid.EngineAlo) {
+			continue
+		}
+		if !mirrored[pasteCloid] {
+			toCancel[pasteCloid] = openPasteOrder
 		}
 	}
+	for pasteCloid := range toCancel {
+		delete(engine.crossingModifies, pasteCloid)
+		if _, done := engine.canceledCloids[pasteCloid]; done {
+			delete(toCancel, pasteCloid)
+		}
+	}
+	engine.pruneLinksLocked()
+
+	return toCreateFinal, toModify, toCancel
+}
 
-	return toCreateFinal, toCancel
+// unmirroredLocked returns the open copy ALO orders on configured coins whose paste order
+// isn't open, whatever was created before, and adds those whose paste order rests at
+// another price to toModify. Orders still parked for the book are left to retryCrossing.
+func (engine *AloEngine) unmirroredLocked(toModify map[string][2]hl.Order) map[string]hl.Order {
+	unmirrored := make(map[string]hl.Order)
+	for copyCloid, order := range engine.manager.CopyWd2.OrdersByCloid() {
+		if _, ok := engine.manager.CoinRiskMap()[order.Coin]; !ok || order.Tif != hl.TifAlo {
+			continue
+		}
+		if _, parked := engine.crossing[copyCloid]; parked {
+			continue
+		}
+		pasteCloid := engine.pasteCloidLocked(copyCloid)
+		if pasteOrder, open := engine.pasteOpenOrders[pasteCloid]; open {
+			if pasteOrder.LimitPx != order.LimitPx {
+				toModify[pasteCloid] = [2]hl.Order{pasteOrder, order}
+			}
+			continue
+		}
+		// the paste order is gone (unwind canceled it, or it was never sent)
+		if _, done := engine.canceledCloids[pasteCloid]; done {
+			delete(engine.canceledCloids, pasteCloid)
+			engine.manager.unjournal(store.KindCanceledCloid, pasteCloid)
+		}
+		unmirrored[copyCloid] = order
+	}
+	return unmirrored
 }
 
 // func parseOpenOrders(openOrders []models.OpenOrder, mgr *Manager) map[string]hl.Order {
@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/itay747/hyperformance/models"
+)
+
+// BookStore keeps the latest l2Book snapshot per coin.
+type BookStore struct {
+	mu    sync.RWMutex
+	books map[string]*models.L2BookSnapshotMessage
+}
+
+func NewBookStore() *BookStore {
+	return &BookStore{books: make(map[string]*models.L2BookSnapshotMessage)}
+}
+
+// Update replaces the coin's snapshot, unless book is older than the one held.
+func (s *BookStore) Update(book *models.L2BookSnapshotMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if held, ok := s.books[book.Coin()]; ok && held.Timestamp() > book.Timestamp() {
+		return
+	}
+	s.books[book.Coin()] = book
+}
+
+func (s *BookStore) Get(coin string) (*models.L2BookSnapshotMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	book, ok := s.books[coin]
+	return book, ok
+}
+
+// Fresh returns the coin's snapshot if it is no older than maxAge.
+func (s *BookStore) Fresh(coin string, maxAge time.Duration) (*models.L2BookSnapshotMessage, bool) {
+	book, ok := s.Get(coin)
+	if !ok || time.Since(time.UnixMilli(book.Timestamp())) > maxAge {
+		return nil, false
+	}
+	return book, true
+}
+
+func (s *BookStore) BestBid(coin string) (models.BookLevel, bool) {
+	book, ok := s.Get(coin)
+	if !ok || len(book.Bids()) == 0 {
+		return models.BookLevel{}, false
+	}
+	return book.BestBid(), true
+}
+
+func (s *BookStore) BestAsk(coin string) (models.BookLevel, bool) {
+	book, ok := s.Get(coin)
+	if !ok || len(book.Asks()) == 0 {
+		return models.BookLevel{}, false
+	}
+	return book.BestAsk(), true
+}
+
+// Depth is the size a buy (asks) or sell (bids) can take at or better than limitPx.
+func (s *BookStore) Depth(coin string, isBuy bool, limitPx float64) float64 {
+	book, ok := s.Get(coin)
+	if !ok {
+		return 0
+	}
+	levels := book.Bids()
+	if isBuy {
+		levels = book.Asks()
+	}
+	var depth float64
+	for _, level := range levels {
+		if isBuy && level.Price > limitPx || !isBuy && level.Price < limitPx {
+			break
+		}
+		depth += level.Size
+	}
+	return depth
+}
+
+// WouldCross reports whether a post-only order at limitPx would take liquidity against
+// a fresh book, and so be rejected.
+func (s *BookStore) WouldCross(coin string, isBuy bool, limitPx float64) bool {
+	book, ok := s.Fresh(coin, maxBookAge)
+	if !ok {
+		return false
+	}
+	if isBuy {
+		asks := book.Asks()
+		return len(asks) > 0 && limitPx >= asks[0].Price
+	}
+	bids := book.Bids()
+	return len(bids) > 0 && limitPx <= bids[0].Price
+}
+
+// SubscribeBookStreams subscribes l2Book for every coin this Manager or a child trades,
+// aggregated to book_n_sig_figs significant figures when set.
+func (manager *Manager) SubscribeBookStreams(connection *websocket.Conn) error {
+	var nSigFigs *int
+	if manager.Config.BookNSigFigs > 0 {
+		nSigFigs = &manager.Config.BookNSigFigs
+	}
+	subscribed := make(map[string]bool)
+	managers := append([]*Manager{manager}, manager.Children...)
+	for _, m := range managers {
+		for _, coinSymbol := range m.streamSymbols {
+			if subscribed[coinSymbol] {
+				continue
+			}
+			subscribed[coinSymbol] = true
+			coin := models.SubscriptionPayload{Coin: coinSymbol, NSigFigs: nSigFigs}
+			if err := connection.WriteJSON(models.NewSubcriptionRequest("l2Book", coin)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
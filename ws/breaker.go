@@ -0,0 +1,277 @@
+package ws
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+)
+
+const defaultTrackingErrorWindow = 30 * time.Second
+
+// Breaker halts both engines of a Manager when the paste account crosses a configured
+// loss threshold, a leader gets liquidated, or the paste side stops tracking the copy
+// target. Once tripped it stays tripped until Resume.
+type Breaker struct {
+	mu      sync.Mutex
+	manager *Manager
+	cfg     config.BreakerConfig
+
+	tripped   bool
+	reason    string
+	trippedAt time.Time
+
+	day           time.Time
+	dayStartValue float64
+	peakValue     float64
+	trackingSince time.Time
+}
+
+func NewBreaker(manager *Manager, cfg config.BreakerConfig) *Breaker {
+	return &Breaker{manager: manager, cfg: cfg}
+}
+
+// Halted reports whether the breaker is tripped. Engines don't send orders while it is.
+func (b *Breaker) Halted() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tripped
+}
+
+// Status returns the trip reason and time, or "" if the breaker isn't tripped.
+func (b *Breaker) Status() (string, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.tripped {
+		return "", time.Time{}
+	}
+	return b.reason, b.trippedAt
+}
+
+// Trip halts the engines and, as configured, cancels paste orders and flattens. Tripping
+// an already tripped breaker keeps the first reason.
+func (b *Breaker) Trip(reason string) {
+	b.mu.Lock()
+	if b.tripped {
+		b.mu.Unlock()
+		return
+	}
+	b.tripped = true
+	b.reason = reason
+	b.trippedAt = time.Now()
+	b.mu.Unlock()
+
+	logger.LogErrorf("[Breaker] paste tripped => %s", reason)
+	if b.cfg.CancelOnTrip || b.cfg.FlattenOnTrip {
+		go b.unwind()
+	}
+}
+
+// Resume clears a trip. Loss thresholds restart from the current account value, and the
+// ALO engine resyncs against the paste book.
+func (b *Breaker) Resume() {
+	b.mu.Lock()
+	if !b.tripped {
+		b.mu.Unlock()
+		return
+	}
+	logger.LogWarnf("[Breaker] paste resumed after %q", b.reason)
+	b.tripped = false
+	b.reason = ""
+	b.day = time.Time{}
+	b.trackingSince = time.Time{}
+	b.mu.Unlock()
+	b.manager.AloEngine.Resync()
+}
+
+func (b *Breaker) unwind() {
+	client := b.manager.Client
+	b.cancelOwnOrders()
+	if !b.cfg.FlattenOnTrip {
+		return
+	}
+	for _, coin := range b.manager.AllowedSymbols() {
+		if _, err := client.ClosePosition(coin); err != nil {
+			logger.LogErrorf("[Breaker] paste ClosePosition %s failed: %v", coin, err)
+		}
+	}
+}
+
+// cancelOwnOrders cancels the open paste orders this bot minted. Orders of other bots
+// and manual orders on the paste account are left alone.
+func (b *Breaker) cancelOwnOrders() {
+	manager := b.manager
+	if manager.PasteWd2 == nil {
+		return
+	}
+	var byCloid []hl.CancelCloidWire
+	for _, order := range manager.PasteWd2.Orders() {
+		if manager.OwnsCloid(order.Cloid) {
+			byCloid = append(byCloid, hl.CancelCloidWire{Asset: manager.MetaMap[order.Coin].AssetID, Cloid: order.Cloid})
+		}
+	}
+	if len(byCloid) == 0 {
+		return
+	}
+	resp, err := manager.Client.BulkCancelOrdersByCloid(byCloid)
+	if err != nil {
+		logger.LogErrorf("[Breaker] paste BulkCancelOrdersByCloid failed: %v", err)
+		return
+	}
+	if resp.Status != "ok" {
+		logger.LogErrorf("[Breaker] paste BulkCancelOrdersByCloid status not ok => %s", resp.Status)
+		return
+	}
+	logger.LogWarnf("[Breaker] paste canceled %d orders", len(byCloid))
+}
+
+// Observe checks every threshold against the latest snapshots. It is called for each
+// new paste, copy and leader webData2.
+func (b *Breaker) Observe() {
+	if b.Halted() {
+		return
+	}
+	if reason := b.check(); reason != "" {
+		b.Trip(reason)
+	}
+}
+
+func (b *Breaker) check() string {
+	manager := b.manager
+	if b.cfg.HaltOnLeaderLiquidation {
+		for _, leader := range manager.Leaders {
+			if coin, ok := manager.liquidatedCoin(leader.Wd2); ok {
+				return fmt.Sprintf("leader %s liquidated on %s", leader.Address, coin)
+			}
+		}
+	}
+	pasteWd2 := manager.PasteWd2
+	if pasteWd2 == nil {
+		return ""
+	}
+	accountValue := pasteWd2.AccountValue()
+	if accountValue <= 0 {
+		return ""
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	if !b.day.Equal(today) {
+		b.day = today
+		b.dayStartValue = accountValue
+		b.peakValue = accountValue
+	}
+	b.peakValue = math.Max(b.peakValue, accountValue)
+	if b.cfg.MaxDrawdownPct > 0 {
+		drawdownPct := (b.peakValue - accountValue) / b.peakValue * 100
+		if drawdownPct >= b.cfg.MaxDrawdownPct {
+			return fmt.Sprintf("drawdown %.2f%% from $%.2f >= %.2f%%", drawdownPct, b.peakValue, b.cfg.MaxDrawdownPct)
+		}
+	}
+	if b.cfg.MaxLoss > 0 {
+		loss := b.dayStartValue - accountValue
+		if loss >= b.cfg.MaxLoss {
+			return fmt.Sprintf("loss $%.2f (unrealized $%.2f) >= $%.2f", loss, unrealizedPnl(pasteWd2), b.cfg.MaxLoss)
+		}
+	}
+	if b.cfg.MaxTrackingErrorPct > 0 && manager.leadersReady() && manager.CopyWd2 != nil {
+		trackingErrorPct := manager.trackingError() / accountValue * 100
+		if trackingErrorPct < b.cfg.MaxTrackingErrorPct {
+			b.trackingSince = time.Time{}
+			return ""
+		}
+		if b.trackingSince.IsZero() {
+			b.trackingSince = now
+		}
+		window := defaultTrackingErrorWindow
+		if b.cfg.TrackingErrorSeconds > 0 {
+			window = time.Duration(b.cfg.TrackingErrorSeconds) * time.Second
+		}
+		if now.Sub(b.trackingSince) >= window {
+			return fmt.Sprintf("tracking error %.2f%% >= %.2f%% for %v", trackingErrorPct, b.cfg.MaxTrackingErrorPct, window)
+		}
+	}
+	return ""
+}
+
+// trackingError is the USD notional between the paste positions and the copy target.
+func (manager *Manager) trackingError() float64 {
+	var total float64
+	for _, drift := range manager.DriftNotional() {
+		total += math.Abs(drift)
+	}
+	return total
+}
+
+// DriftNotional is the signed USD notional per enabled coin that the paste position
+// lacks to reach the copy target.
+func (manager *Manager) DriftNotional() map[string]float64 {
+	targets := manager.TargetPositions()
+	pastePositions := manager.PasteWd2.PositionsByCoin()
+	drift := make(map[string]float64, len(manager.AllowedSymbols()))
+	for _, symbol := range manager.AllowedSymbols() {
+		diff := targets[symbol].Szi - pastePositions[symbol].Szi
+		drift[symbol] = diff * manager.GetMidPrice(symbol)
+	}
+	return drift
+}
+
+// liquidatedCoin reports a position of wd2, or one it held on the previous snapshot,
+// whose mid has traded through its liquidation price.
+func (manager *Manager) liquidatedCoin(wd2 *models.WebData2Message) (string, bool) {
+	if wd2 == nil {
+		return "", false
+	}
+	positions := wd2.Positions()
+	if prev := wd2.Last(); prev != nil {
+		positions = append(positions, prev.Positions()...)
+	}
+	for _, position := range positions {
+		if position.LiquidationPx <= 0 || position.Szi == 0 {
+			continue
+		}
+		mid := manager.GetMidPrice(position.Coin)
+		if mid <= 0 {
+			continue
+		}
+		if position.Szi > 0 && mid <= position.LiquidationPx || position.Szi < 0 && mid >= position.LiquidationPx {
+			return position.Coin, true
+		}
+	}
+	return "", false
+}
+
+func unrealizedPnl(wd2 *models.WebData2Message) float64 {
+	var total float64
+	for _, position := range wd2.Positions() {
+		total += position.UnrealizedPnl
+	}
+	return total
+}
+
+// Halted reports whether this Manager's breaker is tripped.
+func (manager *Manager) Halted() bool {
+	return manager.Breaker.Halted()
+}
+
+// TripAll trips the breaker of this Manager and of every child paste account.
+func (manager *Manager) TripAll(reason string) {
+	manager.Breaker.Trip(reason)
+	for _, child := range manager.Children {
+		child.TripAll(reason)
+	}
+}
+
+// ResumeAll resumes this Manager and every child paste account.
+func (manager *Manager) ResumeAll() {
+	manager.Breaker.Resume()
+	for _, child := range manager.Children {
+		child.ResumeAll()
+	}
+}
@@ -0,0 +1,33 @@
+package ws
+
+import (
+	"testing"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/config"
+)
+
+func TestBreakerCancelsOnlyThisBotsOrders(t *testing.T) {
+	manager, exchange := newTestManager(t, func(cfg *config.HyperformanceConfig) {
+		cfg.BotID = 3
+		cfg.Breaker = config.BreakerConfig{CancelOnTrip: true}
+	})
+	feed(t, manager, 1000, 100000, nil, nil)
+
+	own := manager.NewPasteCloid(cloid.EngineAlo, primaryLeader, 42)
+	otherBot := cloid.ID{BotID: 4, Engine: cloid.EngineAlo, Seq: 1, Source: 42}.String()
+	resting := func(oid int64, c string) hl.Order {
+		return hl.Order{Coin: "BTC", Side: "B", LimitPx: 99000, Sz: 0.1, OrigSz: 0.1, Oid: oid, Cloid: c}
+	}
+	manager.handleWebData2Payload(testWd2Frame(t, testPasteAddress, 2000, 100000, nil,
+		resting(1, own), resting(2, otherBot), resting(3, "")))
+
+	manager.Breaker.unwind()
+	if exchange.cancelAlls != 0 {
+		t.Errorf("CancelAllOrders called %d times, want 0", exchange.cancelAlls)
+	}
+	if len(exchange.cancels) != 1 || exchange.cancels[0].Cloid != own {
+		t.Errorf("canceled %+v, want only %s", exchange.cancels, cloid.Label(own))
+	}
+}
@@ -10,23 +10,45 @@ import (
 
 	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
 	"github.com/gorilla/websocket"
+	"github.com/itay747/hyperformance/cloid"
 	"github.com/itay747/hyperformance/models"
 	"github.com/itay747/hyperformance/utils"
 )
 
 var (
 	endpoint = "wss://api2.hyperliquid.xyz/ws"
-	logger   utils.DualLogger
+	logger   = utils.NewDualLogger(nil)
 )
 
-func (manager *Manager) StartCopyTradingSession(ctx context.Context, logChan chan string) {
-	logger = *utils.NewDualLogger(logChan)
-	logger.LogInfof("[StartCopyTradingSession] single session => copy=%s paste=%s",
-		manager.CopyAddress, manager.PasteAddress)
+// SetLogger replaces the logger of every Manager. Call it before NewManager.
+func SetLogger(l *utils.DualLogger) {
+	logger = l
+}
+
+func (manager *Manager) StartCopyTradingSession(ctx context.Context) {
+	logger.LogInfof("[StartCopyTradingSession] single session => copy=%s paste=%s endpoint=%s",
+		manager.CopyAddress, manager.PasteAddress, manager.Endpoint)
+
+	if manager.ReplayPath != "" {
+		logger.LogWarnf("[StartCopyTradingSession] copy replaying %s at %vx => paste orders are simulated", manager.ReplayPath, manager.ReplaySpeed)
+		if err := manager.Replay(ctx, manager.ReplayPath, manager.ReplaySpeed); err != nil && ctx.Err() == nil {
+			logger.LogErrorf("[StartCopyTradingSession] copy replay => %v", err)
+		}
+		return
+	}
+	go manager.handleFrames(ctx)
+	if manager.Paper != nil {
+		logger.LogWarn("[StartCopyTradingSession] paste dry run => simulated paste account, no orders reach the exchange")
+		go manager.Paper.Run(ctx)
+		for _, child := range manager.Children {
+			go child.Paper.Run(ctx)
+		}
+	}
 
 	baseDelay := time.Second
 	maxDelay := 16 * time.Second
 	attempt := 0
+	connected := false
 
 	dialer := &websocket.Dialer{
 		HandshakeTimeout:  15 * time.Second,
@@ -44,7 +66,7 @@ func (manager *Manager) StartCopyTradingSession(ctx context.Context, logChan cha
 		}
 
 		logger.LogInfof("[StartCopyTradingSession] connecting => attempt %d", attempt+1)
-		conn, _, err := dialer.Dial(endpoint, nil)
+		conn, _, err := dialer.Dial(manager.Endpoint, nil)
 		if err != nil {
 			delay := baseDelay << attempt
 			if delay > maxDelay {
@@ -56,8 +78,20 @@ func (manager *Manager) StartCopyTradingSession(ctx context.Context, logChan cha
 			continue
 		}
 		logger.LogInfo("[StartCopyTradingSession] connected successfully w/ Gorilla WS")
-		manager.SubscribeAllStreams(conn, manager.PasteAddress)
+		if connected {
+			wsReconnects.Inc()
+		}
+		connected = true
+		if manager.Paper == nil {
+			manager.SubscribeAllStreams(conn, manager.PasteAddress)
+		}
 		manager.SubscribeAllStreams(conn, manager.CopyAddress)
+		manager.SubscribeFillStreams(conn)
+		for _, leader := range manager.Leaders[1:] {
+			manager.SubscribeLeaderStreams(conn, leader.Address)
+		}
+		manager.subscribeChildStreams(conn)
+		manager.SubscribeBookStreams(conn)
 
 		go manager.keepConnectionAliveGorilla(conn, 15*time.Second, 30*time.Second)
 
@@ -78,7 +112,7 @@ func (manager *Manager) StartCopyTradingSession(ctx context.Context, logChan cha
 	}
 }
 
-// readWsLoop blocks reading inbound frames, dispatching them to manager.
+// readWsLoop blocks reading inbound frames, queueing them for handleFrames.
 func (manager *Manager) readWsLoop(ctx context.Context, conn *websocket.Conn) error {
 	defer conn.Close()
 
@@ -95,7 +129,29 @@ func (manager *Manager) readWsLoop(ctx context.Context, conn *websocket.Conn) er
 		if msgType != websocket.TextMessage {
 			continue
 		}
-		manager.handleWsRx(data)
+		if manager.Recorder != nil {
+			if err := manager.Recorder.Record(data); err != nil {
+				logger.LogWarnf("[readWsLoop] copy record frame => %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case manager.frames <- data:
+		}
+	}
+}
+
+// handleFrames hands every inbound frame to handleWsRx until ctx is done, so the
+// handlers only ever run on this goroutine.
+func (manager *Manager) handleFrames(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame := <-manager.frames:
+			manager.handleWsRx(frame)
+		}
 	}
 }
 
@@ -109,6 +165,9 @@ func (manager *Manager) handleWsRx(rawData []byte) {
 	if !ok {
 		return
 	}
+	if !manager.isChild {
+		wsFrames.Inc(ch)
+	}
 
 	// If it's a subscribe ack
 	if ch == "subscriptionResponse" {
@@ -123,12 +182,19 @@ func (manager *Manager) handleWsRx(rawData []byte) {
 	switch ch {
 	case "activeAssetData":
 		manager.handleActiveAssetDataPayload(rawData)
+		manager.forwardToChildren(rawData)
 
 	case "l2Book":
 		manager.handleL2BookSnapshotPayload(rawData)
+		manager.forwardToChildren(rawData)
 
 	case "webData2":
 		manager.handleWebData2Payload(rawData)
+		manager.forwardToChildren(rawData)
+
+	case "userFills":
+		manager.handleUserFillsPayload(rawData)
+		manager.forwardToChildren(rawData)
 
 	case "orderUpdates":
 		if !manager.IsReady() {
@@ -176,13 +242,10 @@ func (manager *Manager) keepConnectionAliveGorilla(conn *websocket.Conn, interva
 
 func (manager *Manager) SubscribeAllStreams(connection *websocket.Conn, userAddress string) error {
 	manager.subConfirmChan = make(chan struct{})
-	requiredSubscriptions := len(manager.AllowedSymbols) + 2
+	requiredSubscriptions := len(manager.streamSymbols) + 2
 	atomic.StoreInt32(&manager.subNeeded, int32(requiredSubscriptions))
 
-	for coinSymbol := range manager.MetaMap {
-		if !manager.IsEnabledCoin(coinSymbol) {
-			continue
-		}
+	for _, coinSymbol := range manager.streamSymbols {
 		userCoin := models.SubscriptionPayload{Coin: coinSymbol, User: userAddress}
 		subRequest := models.NewSubcriptionRequest("activeAssetData", userCoin)
 		writeErr := connection.WriteJSON(subRequest)
@@ -195,12 +258,9 @@ func (manager *Manager) SubscribeAllStreams(connection *websocket.Conn, userAddr
 	if webErr != nil {
 		return webErr
 	}
-	if userAddress == manager.CopyAddress {
-		orderErr := connection.WriteJSON(models.NewSubcriptionRequest("orderUpdates", userCoin))
-		if orderErr != nil {
-			return orderErr
-		}
-
+	orderErr := connection.WriteJSON(models.NewSubcriptionRequest("orderUpdates", userCoin))
+	if orderErr != nil {
+		return orderErr
 	}
 
 	return nil
@@ -264,7 +324,7 @@ func (manager *Manager) handleL2BookSnapshotPayload(rawData []byte) {
 func (manager *Manager) handleWebData2Payload(rawData []byte) {
 	var wd2 *models.WebData2Message
 	json.Unmarshal(rawData, &wd2)
-	for _, symbol := range manager.AllowedSymbols {
+	for _, symbol := range manager.AllowedSymbols() {
 		assetInfo, foundAsset := manager.MetaMap[symbol]
 		if foundAsset {
 			manager.AssetCtxStore.Store(symbol, wd2.Data.AssetCtxs[assetInfo.AssetID])
@@ -272,6 +332,7 @@ func (manager *Manager) handleWebData2Payload(rawData []byte) {
 	}
 	if wd2.Data.User == manager.CopyAddress && manager.lastCopyWd2ChTime != wd2.ClearinghouseTime() {
 		manager.CopyWd2 = wd2.AddPrev(manager.CopyWd2)
+		manager.Leaders[0].Wd2 = manager.CopyWd2
 		if manager.PasteWd2 != nil && manager.CopyWd2.ClearinghouseTime().Equal(manager.PasteWd2.ClearinghouseTime()) {
 			if !manager.CopyWd2.IsHead() && !manager.PasteWd2.IsHead() {
 				manager.CopyWd2.AddOther(manager.PasteWd2)
@@ -279,36 +340,28 @@ func (manager *Manager) handleWebData2Payload(rawData []byte) {
 		}
 		manager.CopyWebSocketReady = true
 		manager.lastCopyWd2ChTime = wd2.ClearinghouseTime()
+		manager.observeWebData2(wd2, "copy")
 		manager.CopyWd2Chan <- wd2
 	} else if wd2.Data.User == manager.PasteAddress && manager.lastPasteWd2ChTime != wd2.ClearinghouseTime() {
 		manager.PasteWd2 = wd2.AddPrev(manager.PasteWd2)
+		manager.IocInFlight.SyncClock(wd2.ServerTime())
 		manager.PasteWebSocketReady = true
 		manager.lastPasteWd2ChTime = wd2.ClearinghouseTime()
+		manager.observeWebData2(wd2, "paste")
 		manager.PasteWd2Chan <- wd2
+	} else if leader := manager.secondaryLeader(wd2.Data.User); leader != nil {
+		manager.handleLeaderWd2(leader, wd2)
 	}
+	manager.Breaker.Observe()
 }
 
-// func (m *Manager) updateInFlightForWD2(oldWd2, newWd2 models.WebData2Message) {
-// 	if newWd2.Data.User != m.PasteAddress {
-// 		return
-// 	}
-// 	oldMap := oldWd2.PositionsByCoin()
-// 	newMap := newWd2.PositionsByCoin()
-// 	for coin, newPos := range newMap {
-// 		oldPos := oldMap[coin]
-// 		delta := newPos.Szi - oldPos.Szi
-// 		if math.Abs(delta) > 1e-8 {
-// 			m.IocEngine.AdjustInFlight(coin, delta)
-// 		}
-// 	}
-// }
-
 func (engine *AloEngine) processNewAloOrders(copySideOrders map[string]hl.Order) {
-	if len(copySideOrders) == 0 {
+	if len(copySideOrders) == 0 || engine.manager.Halted() || engine.manager.EnginePaused(EngineAlo) {
 		return
 	}
 	var pasteRequests []hl.OrderRequest
-	for cloid, copyOrderAsBase := range copySideOrders {
+	copyCloids := make(map[string]string)
+	for copyCloid, copyOrderAsBase := range copySideOrders {
 		_, foundCoin := engine.manager.MetaMap[copyOrderAsBase.Coin]
 		if !foundCoin {
 			logger.LogInfof("processNewAloOrders skipping unrecognized coin => coin=%s", copyOrderAsBase.Coin)
@@ -322,13 +375,18 @@ func (engine *AloEngine) processNewAloOrders(copySideOrders map[string]hl.Order)
 		if copyOrderAsBase.Tif != hl.TifAlo {
 			continue
 		}
+		if engine.manager.Books.WouldCross(copyOrderAsBase.Coin, isBuy, copyOrderAsBase.LimitPx) {
+			logger.LogFields("info", fmt.Sprintf("paste alo %s px=%v would cross the book => waiting", copyOrderAsBase.Coin, copyOrderAsBase.LimitPx), utils.OrderFields(copyOrderAsBase))
+			engine.deferCrossing(copyCloid, copyOrderAsBase)
+			continue
+		}
 		pasteSz := engine.manager.scaleSize(copyOrderAsBase)
 		decimals := engine.manager.Decimals(copyOrderAsBase.Coin)
 		copyOrderAsBase.Sz = hl.SizeToFloat(pasteSz, decimals)
 		if copyOrderAsBase.Sz == 0 {
-			logger.LogErrorf("paste issue with scaledSz: %v", copyOrderAsBase.Sz)
+			// capped_leverage sizes orders past the cap to nothing
+			logger.LogInfof("paste alo %s sized to 0 => skipping", copyOrderAsBase.Coin)
 			continue
-
 		}
 		orderRequest := hl.OrderRequest{
 			Coin:       copyOrderAsBase.Coin,
@@ -337,22 +395,41 @@ func (engine *AloEngine) processNewAloOrders(copySideOrders map[string]hl.Order)
 			Sz:         copyOrderAsBase.Sz,
 			OrderType:  hl.OrderType{Limit: &hl.LimitOrderType{Tif: hl.TifAlo}},
 			ReduceOnly: copyOrderAsBase.ReduceOnly,
-			Cloid:      cloid,
+			Cloid:      engine.MintPasteCloid(copyCloid),
 		}
 		notionalValue := orderRequest.Sz * orderRequest.LimitPx
 		if notionalValue > minNotionalDiff {
 			pasteRequests = append(pasteRequests, orderRequest)
+			copyCloids[orderRequest.Cloid] = copyCloid
+		}
+	}
+	allowed := engine.manager.RiskGate.Filter(pasteRequests)
+	passed := make(map[string]bool, len(allowed))
+	for _, request := range allowed {
+		passed[request.Cloid] = true
+	}
+	var rejected []string
+	for _, request := range pasteRequests {
+		if !passed[request.Cloid] {
+			rejected = append(rejected, copyCloids[request.Cloid])
 		}
 	}
+	engine.unmarkCreated(rejected)
+	pasteRequests = allowed
 	if len(pasteRequests) == 0 {
 		return
 	}
-	bulkResponse, bulkErr := engine.manager.Client.BulkOrders(pasteRequests, hl.GroupingNa)
+	engine.manager.syncLeverage(pasteRequests)
+	bulkResponse, bulkErr := engine.manager.bulkOrders(EngineAlo, pasteRequests, hl.GroupingNa)
 	if bulkErr != nil {
 		logger.LogErrorf("paste BulkOrders error => %v", bulkErr)
+		engine.unmarkCreated(sentCopyCloids(pasteRequests, copyCloids))
+		return
 	}
 	if bulkResponse.Status != "ok" {
 		logger.LogErrorf("paste BulkOrders status not ok => %s", bulkResponse.Status)
+		engine.unmarkCreated(sentCopyCloids(pasteRequests, copyCloids))
+		return
 	}
 	statuses := bulkResponse.Response.Data.Statuses
 	for _, statusItem := range statuses {
@@ -364,6 +441,15 @@ func (engine *AloEngine) processNewAloOrders(copySideOrders map[string]hl.Order)
 	}
 }
 
+// sentCopyCloids returns the copy cloids behind requests, from paste cloid to copy cloid.
+func sentCopyCloids(requests []hl.OrderRequest, copyCloids map[string]string) []string {
+	sent := make([]string, 0, len(requests))
+	for _, request := range requests {
+		sent = append(sent, copyCloids[request.Cloid])
+	}
+	return sent
+}
+
 func (manager *Manager) handleActiveAssetDataPayload(jsonData []byte) {
 	var activeAssetMessage models.ActiveAssetDataMessage
 	unmarshalErr := json.Unmarshal(jsonData, &activeAssetMessage)
@@ -378,12 +464,12 @@ func (eng *AloEngine) processCancelRequests(ordersToCancel map[string]hl.Order)
 	}
 	var byCloid []hl.CancelCloidWire
 
-	for cloid, orderToCancel := range ordersToCancel {
+	for pasteCloid, orderToCancel := range ordersToCancel {
 		meta := eng.manager.MetaMap[orderToCancel.Coin]
 		if orderToCancel.Cloid != "" {
 			byCloid = append(byCloid, hl.CancelCloidWire{
 				Asset: meta.AssetID,
-				Cloid: cloid,
+				Cloid: pasteCloid,
 			})
 		} else {
 			logger.LogErrorf("Cancelling with an empty cloid: %#+v", orderToCancel)
@@ -404,86 +490,72 @@ func (eng *AloEngine) processCancelRequests(ordersToCancel map[string]hl.Order)
 		return
 	}
 	for i, st := range resp.Response.Data.Statuses {
+		if st.Error == "" && i < len(byCloid) {
+			eng.markCanceled(byCloid[i].Cloid)
+		}
 		if st.Error != "" {
-			cloid := byCloid[i].Cloid
-			cloidInt, _ := hl.HexToInt(cloid)
-			order := ordersToCancel[cloid]
-			logger.LogErrorf(fmt.Sprintf("[aloCancel] paste cloid: %v | %s | Err: %s... ", cloidInt, order.Coin, st.Error[:10]))
+			pasteCloid := byCloid[i].Cloid
+			order := ordersToCancel[pasteCloid]
+			logger.LogErrorf(fmt.Sprintf("[aloCancel] paste cloid: %v | %s | Err: %s... ", cloid.Label(pasteCloid), order.Coin, st.Error[:10]))
 		}
 	}
 }
 
-// func (manager *Manager) processOrderModifications(modifications []models.OrderModification) {
-// 	if len(modifications) == 0 {
-// 		return
-// 	}
-// 	var requests []hl.OrderRequest
-// 	for _, mod := range modifications {
-// 		if mod.Mapping.PasteOid == 0 && mod.Order.Cloid == "" {
-// 			continue
-// 		}
-// 		oldPasteOID := int(mod.Mapping.PasteOid)
-// 		request := hl.OrderRequest{
-// 			Coin:       mod.Order.Coin,
-// 			IsBuy:      mod.Order.Side == "B",
-// 			Sz:         mod.Order.Sz,
-// 			LimitPx:    mod.Order.LimitPx,
-// 			OrderType:  hl.OrderType{Limit: &hl.LimitOrderType{Tif: hl.TifAlo}},
-// 			ReduceOnly: mod.Order.ReduceOnly,
-// 			Cloid:      mod.Order.Cloid, // The NEW CLOID (for the modify)
-// 		}
-// 		if oldPasteOID != 0 {
-// 			request.OrderID = &oldPasteOID
-// 		}
-// 		requests = append(requests, request)
-// 	}
-
-// 	if len(requests) == 0 {
-// 		return
-// 	}
-// 	resp, err := manager.Client.BulkModifyOrdersByCloid(requests)
-// 	if err != nil {
-// 		logger.LogErrorf("[processOrderModifications] BulkModifyOrdersByCloid error => %v", err)
-// 		cancelResp, cancelErr := manager.Client.CancelAllOrders()
-// 		if cancelErr != nil {
-// 			logger.LogErrorf("[processOrderModifications] fallback CancelAllOrders => %v", cancelErr)
-// 		}
-// 		if cancelResp != nil && cancelResp.Status != "ok" {
-// 			logger.LogWarnf("[processOrderModifications] fallback CancelAllOrders => status=%s", cancelResp.Status)
-// 		}
-// 		return
-// 	}
-// 	if resp.Status != "ok" {
-// 		logger.LogErrorf("[processOrderModifications] paste Non-ok status => %s", resp.Status)
-// 		return
-// 	}
-// 	for i, statusItem := range resp.Response.Data.Statuses {
-// 		if statusItem.Error != "" || statusItem.Resting.OrderID == 0 {
-// 			continue
-// 		}
-// 		req := requests[i]
-// 		newPasteOid := int64(statusItem.Resting.OrderID)
-// 		// find matching mod
-// 		var oldCopyOid, newCopyOid int64
-// 		var oldCloid, newCloid string
-// 		for _, mod := range modifications {
-// 			sameCoin := (mod.Order.Coin == req.Coin)
-// 			matchOid := (req.OrderID != nil && mod.Mapping.PasteOid == int64(*req.OrderID))
-// 			matchCloid := (mod.Mapping.Cloid != "" && mod.Mapping.Cloid == mod.Order.Cloid)
-// 			if sameCoin && (matchOid || matchCloid) {
-// 				oldCopyOid = mod.Mapping.CopyOid
-// 				newCopyOid = mod.Order.Oid
-// 				oldCloid = mod.Mapping.Cloid
-// 				newCloid = mod.Order.Cloid
-// 				break
-// 			}
-// 		}
-// 		if oldCopyOid == 0 {
-// 			continue
-// 		}
-// 	}
-
-// }
+// processAloModifications moves paste ALO orders after the copy orders they mirror,
+// keyed by paste cloid, to the new price and the new size scaled.
+func (engine *AloEngine) processAloModifications(modifications map[string][2]hl.Order) {
+	if len(modifications) == 0 || engine.manager.Halted() || engine.manager.EnginePaused(EngineAlo) {
+		return
+	}
+	var modifyRequests []hl.OrderRequest
+	for pasteCloid, pair := range modifications {
+		copyOrder := pair[1]
+		if copyOrder.Tif != hl.TifAlo {
+			continue
+		}
+		isBuy := copyOrder.Side == "B"
+		if engine.manager.Books.WouldCross(copyOrder.Coin, isBuy, copyOrder.LimitPx) {
+			logger.LogFields("info", fmt.Sprintf("paste alo modify %s px=%v would cross the book => keeping the old order until it no longer would", copyOrder.Coin, copyOrder.LimitPx), utils.OrderFields(copyOrder))
+			engine.deferCrossingModify(pasteCloid, pair)
+			continue
+		}
+		pasteSz := hl.SizeToFloat(engine.manager.scaleSize(copyOrder), engine.manager.Decimals(copyOrder.Coin))
+		if pasteSz == 0 || pasteSz*copyOrder.LimitPx < minNotionalDiff {
+			logger.LogInfof("paste alo modify %s sz=%v too small => skipping", copyOrder.Coin, pasteSz)
+			continue
+		}
+		modified := utils.OrderFields(copyOrder)
+		modified.Cloid, modified.Size = pasteCloid, pasteSz
+		logger.LogFields("info", "copy "+logger.FormatModificationCondensed(pair[0], pair[1]), modified)
+		modifyRequests = append(modifyRequests, hl.OrderRequest{
+			Coin:       copyOrder.Coin,
+			IsBuy:      isBuy,
+			LimitPx:    copyOrder.LimitPx,
+			Sz:         pasteSz,
+			OrderType:  hl.OrderType{Limit: &hl.LimitOrderType{Tif: hl.TifAlo}},
+			ReduceOnly: copyOrder.ReduceOnly,
+			Cloid:      pasteCloid,
+		})
+	}
+	modifyRequests = engine.manager.RiskGate.Filter(modifyRequests)
+	if len(modifyRequests) == 0 {
+		return
+	}
+	resp, err := engine.manager.bulkModifyOrdersByCloid(EngineAlo, modifyRequests)
+	if err != nil {
+		logger.LogErrorf("paste BulkModifyOrdersByCloid error => %v", err)
+		return
+	}
+	if resp.Status != "ok" {
+		logger.LogErrorf("paste BulkModifyOrdersByCloid status not ok => %s", resp.Status)
+		return
+	}
+	for i, statusItem := range resp.Response.Data.Statuses {
+		if statusItem.Error != "" && i < len(modifyRequests) {
+			logger.LogErrorf("[aloModify] paste %s cloid=%s => %s", modifyRequests[i].Coin, modifyRequests[i].Cloid, statusItem.Error)
+		}
+	}
+}
 
 func (manager *Manager) makeModifiedOrder(openUpdate, canceledUpdate models.OrderUpdate) (*hl.Order, error) {
 	if openUpdate.Status != "open" || canceledUpdate.Status != "canceled" {
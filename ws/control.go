@@ -0,0 +1,175 @@
+package ws
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Engine names the control API pauses and resumes.
+const (
+	EngineIoc     = "ioc"
+	EngineAlo     = "alo"
+	EngineTrigger = "trigger"
+	EngineFill    = "fill"
+)
+
+var engineNames = []string{EngineIoc, EngineAlo, EngineTrigger, EngineFill}
+
+// SetEnginePaused pauses or resumes one engine of this Manager. A paused engine sends
+// nothing; on resume the ALO engine resyncs against the paste book. The fill engine sends
+// through the IOC engine, so pausing ioc pauses it too.
+func (manager *Manager) SetEnginePaused(engine string, paused bool) error {
+	if !validEngine(engine) {
+		return fmt.Errorf("unknown engine %q, want one of %s", engine, strings.Join(engineNames, ", "))
+	}
+	manager.controlMu.Lock()
+	wasPaused := manager.pausedEngines[engine]
+	manager.pausedEngines[engine] = paused
+	manager.controlMu.Unlock()
+	if engine == EngineAlo && wasPaused && !paused {
+		manager.AloEngine.Resync()
+	}
+	logger.LogWarnf("[Control] paste %s engine paused=%v", engine, paused)
+	return nil
+}
+
+func (manager *Manager) EnginePaused(engine string) bool {
+	manager.controlMu.Lock()
+	defer manager.controlMu.Unlock()
+	return manager.pausedEngines[engine]
+}
+
+// EngineStates reports whether each engine is enabled in the config and paused.
+func (manager *Manager) EngineStates() map[string]EngineState {
+	enabled := map[string]bool{
+		EngineIoc:     manager.IocEngine.enabled,
+		EngineAlo:     manager.AloEngine.enabled,
+		EngineTrigger: manager.TriggerEngine.enabled,
+		EngineFill:    manager.FillEngine.enabled,
+	}
+	states := make(map[string]EngineState, len(engineNames))
+	for _, engine := range engineNames {
+		states[engine] = EngineState{Enabled: enabled[engine], Paused: manager.EnginePaused(engine)}
+	}
+	return states
+}
+
+type EngineState struct {
+	Enabled bool `json:"enabled"`
+	Paused  bool `json:"paused"`
+}
+
+func validEngine(engine string) bool {
+	for _, name := range engineNames {
+		if name == engine {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCoinWeight changes a configured coin's weight in CoinRiskMap, which every leader
+// sharing the top-level coins map is sized with.
+func (manager *Manager) SetCoinWeight(coin string, weight float64) error {
+	if weight <= 0 {
+		return fmt.Errorf("weight must be positive, got %v", weight)
+	}
+	manager.controlMu.Lock()
+	defer manager.controlMu.Unlock()
+	if _, ok := manager.coinRiskMap[coin]; !ok {
+		return fmt.Errorf("coin %s is not configured", coin)
+	}
+	// copy on write: callers keep the map CoinRiskMap returned
+	weights := make(map[string]float64, len(manager.coinRiskMap))
+	for symbol, w := range manager.coinRiskMap {
+		weights[symbol] = w
+	}
+	weights[coin] = weight
+	manager.coinRiskMap = weights
+	logger.LogWarnf("[Control] paste %s weight => %v", coin, weight)
+	return nil
+}
+
+// SetCoinEnabled adds a coin enabled at startup back to AllowedSymbols or removes it. A
+// disabled coin is neither mirrored nor reconciled; its position and orders are left as
+// they are. Its streams stay subscribed, which is why only startup coins can be enabled.
+func (manager *Manager) SetCoinEnabled(coin string, enabled bool) error {
+	manager.controlMu.Lock()
+	defer manager.controlMu.Unlock()
+	if _, ok := manager.coinRiskMap[coin]; !ok {
+		return fmt.Errorf("coin %s is not configured", coin)
+	}
+	if _, ok := manager.MetaMap[coin]; !ok {
+		return fmt.Errorf("coin %s is not listed", coin)
+	}
+	if enabled && !slices.Contains(manager.streamSymbols, coin) {
+		return fmt.Errorf("coin %s was not enabled at startup, restart to enable it", coin)
+	}
+	symbols := make([]string, 0, len(manager.allowedSymbols)+1)
+	for _, symbol := range manager.allowedSymbols {
+		if symbol != coin {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if enabled {
+		symbols = append(symbols, coin)
+		sort.Strings(symbols)
+	}
+	manager.allowedSymbols = symbols
+	logger.LogWarnf("[Control] paste %s enabled=%v", coin, enabled)
+	return nil
+}
+
+// Flatten cancels the paste orders on coin and closes its position, or does so for the
+// whole paste account when coin is "". The engines keep running, so pause them or
+// disable the coin first unless the copy side is flat too.
+func (manager *Manager) Flatten(coin string) error {
+	if manager.PasteWd2 == nil {
+		return fmt.Errorf("no paste webData2 yet")
+	}
+	logger.LogWarnf("[Control] paste flatten %q", coin)
+	var errs []string
+	if coin == "" {
+		if _, err := manager.Client.CancelAllOrders(); err != nil {
+			errs = append(errs, fmt.Sprintf("CancelAllOrders: %v", err))
+		}
+	} else {
+		for _, order := range manager.PasteWd2.Orders() {
+			if order.Coin != coin {
+				continue
+			}
+			if _, err := manager.Client.CancelOrderByOID(coin, int(order.Oid)); err != nil {
+				errs = append(errs, fmt.Sprintf("cancel %s oid=%d: %v", coin, order.Oid, err))
+			}
+		}
+	}
+	for symbol, position := range manager.PasteWd2.PositionsByCoin() {
+		if coin != "" && symbol != coin || position.Szi == 0 {
+			continue
+		}
+		if _, err := manager.Client.ClosePosition(symbol); err != nil {
+			errs = append(errs, fmt.Sprintf("ClosePosition %s: %v", symbol, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("flatten: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Account returns this Manager if address is "" or its paste address, else the child
+// trading address.
+func (manager *Manager) Account(address string) (*Manager, bool) {
+	address = strings.ToLower(address)
+	if address == "" || address == manager.PasteAddress {
+		return manager, true
+	}
+	for _, child := range manager.Children {
+		if child.PasteAddress == address {
+			return child, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+)
+
+// Exchange is the paste-side trading API the Manager and its engines depend on.
+// *hl.Hyperliquid is the live implementation; paper trading, recording or fault
+// injection can stand in for it.
+type Exchange interface {
+	// Orders
+	BulkOrders(requests []hl.OrderRequest, grouping hl.Grouping) (*hl.OrderResponse, error)
+	BulkModifyOrdersByCloid(requests []hl.OrderRequest) (*hl.OrderResponse, error)
+
+	// Cancels
+	BulkCancelOrdersByCloid(cancels []hl.CancelCloidWire) (*hl.OrderResponse, error)
+	CancelOrderByOID(coin string, orderID int) (*hl.OrderResponse, error)
+	CancelAllOrders() (*hl.OrderResponse, error)
+	ClosePosition(coin string) (*hl.OrderResponse, error)
+
+	// Account
+	UpdateLeverage(coin string, isCross bool, leverage int) (*hl.DefaultExchangeResponse, error)
+
+	// Meta
+	BuildMetaMap() (map[string]hl.AssetInfo, error)
+}
+
+var _ Exchange = (*hl.Hyperliquid)(nil)
+
+var errNoSecretKey = errors.New("no secret key configured for this paste account")
+
+// infoExchange serves the meta of the info API and refuses everything that needs a
+// signature. It stands in for the live client of a read-only Manager without a key.
+type infoExchange struct {
+	info *hl.InfoAPI
+}
+
+func newInfoExchange() *infoExchange {
+	return &infoExchange{info: hl.NewInfoAPI(true)}
+}
+
+func (e *infoExchange) BulkOrders(requests []hl.OrderRequest, grouping hl.Grouping) (*hl.OrderResponse, error) {
+	return nil, errNoSecretKey
+}
+
+func (e *infoExchange) BulkModifyOrdersByCloid(requests []hl.OrderRequest) (*hl.OrderResponse, error) {
+	return nil, errNoSecretKey
+}
+
+func (e *infoExchange) BulkCancelOrdersByCloid(cancels []hl.CancelCloidWire) (*hl.OrderResponse, error) {
+	return nil, errNoSecretKey
+}
+
+func (e *infoExchange) CancelOrderByOID(coin string, orderID int) (*hl.OrderResponse, error) {
+	return nil, errNoSecretKey
+}
+
+func (e *infoExchange) CancelAllOrders() (*hl.OrderResponse, error) {
+	return nil, errNoSecretKey
+}
+
+func (e *infoExchange) ClosePosition(coin string) (*hl.OrderResponse, error) {
+	return nil, errNoSecretKey
+}
+
+func (e *infoExchange) UpdateLeverage(coin string, isCross bool, leverage int) (*hl.DefaultExchangeResponse, error) {
+	return nil, errNoSecretKey
+}
+
+func (e *infoExchange) BuildMetaMap() (map[string]hl.AssetInfo, error) {
+	// InfoAPI.BuildMetaMap exits the process on a failed request
+	response, err := e.info.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("fetch meta: %w", err)
+	}
+	return config.BuildMetaMap(response)
+}
@@ -0,0 +1,233 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+)
+
+const (
+	testCopyAddress  = "0x1111111111111111111111111111111111111111"
+	testPasteAddress = "0x2222222222222222222222222222222222222222"
+)
+
+// stubExchange records what the engines send and fills every order in full at its limit.
+type stubExchange struct {
+	mu       sync.Mutex
+	meta     map[string]hl.AssetInfo
+	orders   []hl.OrderRequest
+	modifies []hl.OrderRequest
+	cancels  []hl.CancelCloidWire
+	// cancelAlls counts CancelAllOrders calls.
+	cancelAlls int
+}
+
+func newStubExchange() *stubExchange {
+	return &stubExchange{meta: map[string]hl.AssetInfo{
+		"BTC": {AssetID: 0, SzDecimals: 5},
+		"ETH": {AssetID: 1, SzDecimals: 4},
+	}}
+}
+
+func (s *stubExchange) BulkOrders(requests []hl.OrderRequest, grouping hl.Grouping) (*hl.OrderResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders = append(s.orders, requests...)
+	var statuses []hl.StatusResponse
+	for _, request := range requests {
+		statuses = append(statuses, hl.StatusResponse{Filled: hl.FilledStatus{TotalSz: request.Sz, AvgPx: request.LimitPx, Cloid: request.Cloid}})
+	}
+	return paperOrderResponse(statuses), nil
+}
+
+func (s *stubExchange) BulkModifyOrdersByCloid(requests []hl.OrderRequest) (*hl.OrderResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modifies = append(s.modifies, requests...)
+	statuses := make([]hl.StatusResponse, len(requests))
+	return paperOrderResponse(statuses), nil
+}
+
+func (s *stubExchange) BulkCancelOrdersByCloid(cancels []hl.CancelCloidWire) (*hl.OrderResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancels = append(s.cancels, cancels...)
+	statuses := make([]hl.StatusResponse, len(cancels))
+	return paperOrderResponse(statuses), nil
+}
+
+func (s *stubExchange) CancelOrderByOID(coin string, orderID int) (*hl.OrderResponse, error) {
+	return paperOrderResponse(nil), nil
+}
+
+func (s *stubExchange) CancelAllOrders() (*hl.OrderResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelAlls++
+	return paperOrderResponse(nil), nil
+}
+
+func (s *stubExchange) ClosePosition(coin string) (*hl.OrderResponse, error) {
+	return paperOrderResponse(nil), nil
+}
+
+func (s *stubExchange) UpdateLeverage(coin string, isCross bool, leverage int) (*hl.DefaultExchangeResponse, error) {
+	return &hl.DefaultExchangeResponse{Status: "ok"}, nil
+}
+
+func (s *stubExchange) BuildMetaMap() (map[string]hl.AssetInfo, error) {
+	return s.meta, nil
+}
+
+func (s *stubExchange) sentOrders() []hl.OrderRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]hl.OrderRequest(nil), s.orders...)
+}
+
+// newTestManager builds a Manager trading BTC through a stubExchange. edit, if given,
+// adjusts the config first.
+func newTestManager(t *testing.T, edit func(*config.HyperformanceConfig)) (*Manager, *stubExchange) {
+	t.Helper()
+	cfg := &config.HyperformanceConfig{
+		CopyAddress:  testCopyAddress,
+		PasteAddress: testPasteAddress,
+		CoinRiskMap:  map[string]float64{"BTC": 1},
+	}
+	if edit != nil {
+		edit(cfg)
+	}
+	exchange := newStubExchange()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewManagerWithExchange(ctx, cfg, exchange), exchange
+}
+
+// testWd2Frame is a raw webData2 frame for user at chTime with a BTC mid of midPx,
+// holding the signed positions and resting orders given.
+func testWd2Frame(t *testing.T, user string, chTime int64, midPx float64, positions map[string]float64, orders ...hl.Order) []byte {
+	t.Helper()
+	mid := strconv.FormatFloat(midPx, 'f', -1, 64)
+	var assetPositions []any
+	totalNtl := 0.0
+	for coin, szi := range positions {
+		value := szi * midPx
+		if value < 0 {
+			value = -value
+		}
+		totalNtl += value
+		assetPositions = append(assetPositions, map[string]any{
+			"type": "oneWay",
+			"position": map[string]any{
+				"coin":          coin,
+				"szi":           strconv.FormatFloat(szi, 'f', -1, 64),
+				"positionValue": strconv.FormatFloat(value, 'f', -1, 64),
+				"entryPx":       mid,
+				"leverage":      map[string]any{"type": "cross", "value": 10},
+			},
+		})
+	}
+	if orders == nil {
+		orders = []hl.Order{}
+	}
+	frame, err := json.Marshal(map[string]any{
+		"channel": "webData2",
+		"data": map[string]any{
+			"user":       user,
+			"serverTime": chTime,
+			"clearinghouseState": map[string]any{
+				"marginSummary": map[string]any{
+					"accountValue":    "10000",
+					"totalNtlPos":     strconv.FormatFloat(totalNtl, 'f', -1, 64),
+					"totalRawUsd":     "10000",
+					"totalMarginUsed": "0",
+				},
+				"assetPositions": assetPositions,
+				"time":           chTime,
+			},
+			"openOrders": orders,
+			"assetCtxs": []any{
+				map[string]any{"midPx": mid, "markPx": mid, "oraclePx": mid},
+				map[string]any{"midPx": "2000", "markPx": "2000", "oraclePx": "2000"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+// testAssetData is activeAssetData for coin with free USD available to trade at 10x.
+func testAssetData(user, coin string, free, maxTradeSz float64) models.UserAssetData {
+	data := models.UserAssetData{
+		User:             user,
+		Coin:             coin,
+		MaxTradeSzs:      []float64{maxTradeSz, maxTradeSz},
+		AvailableToTrade: []float64{free, free},
+	}
+	data.Leverage.Type = "cross"
+	data.Leverage.Value = 10
+	return data
+}
+
+// feed delivers a copy and a paste snapshot at chTime, and paste asset data, leaving
+// manager ready. The snapshots queued for the engines are drained.
+func feed(t *testing.T, manager *Manager, chTime int64, midPx float64, copyPositions, pastePositions map[string]float64) {
+	t.Helper()
+	for _, coin := range manager.streamSymbols {
+		manager.handleActiveAssetData(testAssetData(testCopyAddress, coin, 10000, 10))
+		manager.handleActiveAssetData(testAssetData(testPasteAddress, coin, 10000, 10))
+	}
+	manager.handleWebData2Payload(testWd2Frame(t, testPasteAddress, chTime, midPx, pastePositions))
+	manager.handleWebData2Payload(testWd2Frame(t, testCopyAddress, chTime, midPx, copyPositions))
+	for len(manager.CopyWd2Chan) > 0 {
+		<-manager.CopyWd2Chan
+	}
+	for len(manager.PasteWd2Chan) > 0 {
+		<-manager.PasteWd2Chan
+	}
+	if !manager.IsReady() {
+		t.Fatal("manager not ready after feed")
+	}
+}
+
+// testBook is an l2Book snapshot for coin taken now, with levels given as {px, sz} pairs,
+// best first.
+func testBook(t *testing.T, coin string, bids, asks [][2]float64) *models.L2BookSnapshotMessage {
+	t.Helper()
+	side := func(levels [][2]float64) []any {
+		out := []any{}
+		for _, level := range levels {
+			out = append(out, map[string]any{
+				"px": strconv.FormatFloat(level[0], 'f', -1, 64),
+				"sz": strconv.FormatFloat(level[1], 'f', -1, 64),
+				"n":  1,
+			})
+		}
+		return out
+	}
+	frame, err := json.Marshal(map[string]any{
+		"channel": "l2Book",
+		"data": map[string]any{
+			"coin":   coin,
+			"time":   time.Now().UnixMilli(),
+			"levels": []any{side(bids), side(asks)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var book models.L2BookSnapshotMessage
+	if err := json.Unmarshal(frame, &book); err != nil {
+		t.Fatal(err)
+	}
+	return &book
+}
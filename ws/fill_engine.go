@@ -0,0 +1,243 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/gorilla/websocket"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/models"
+	"github.com/itay747/hyperformance/utils"
+)
+
+// FillEngine mirrors the primary copy account from its userFills instead of pairing
+// open and filled orderUpdates, so TWAP slices, liquidations and orders placed outside
+// the cloid scheme are mirrored too. Each fill is mirrored once by tid; fills in a
+// snapshot happened before we subscribed and are left to the reconcile loop.
+//
+// Disabled, it still tracks the fill price of each copy order for the IOC engine.
+type FillEngine struct {
+	manager  *Manager
+	enabled  bool
+	seenTids map[int64]time.Time
+
+	pxMu  sync.Mutex
+	byOid map[int64]*oidFills
+}
+
+// fillPxWait bounds how long the IOC engine waits for the copy fills of a filled order
+// before pricing its mirror off the mid.
+const fillPxWait = 500 * time.Millisecond
+
+// oidFills sums the copy fills of one order. arrived is closed on the first fill.
+type oidFills struct {
+	notional float64
+	size     float64
+	// filledAt is the exchange time of the latest fill.
+	filledAt time.Time
+	seenAt   time.Time
+	arrived  chan struct{}
+}
+
+func NewFillEngine(ctx context.Context, m *Manager, enabled bool) *FillEngine {
+	return &FillEngine{
+		manager:  m,
+		enabled:  enabled,
+		seenTids: make(map[int64]time.Time),
+		byOid:    make(map[int64]*oidFills),
+	}
+}
+
+func (engine *FillEngine) Start(ctx context.Context, userFillsChan <-chan *models.UserFillsMessage) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case userFills := <-userFillsChan:
+				engine.handleUserFills(userFills)
+			}
+		}
+	}()
+}
+
+func (engine *FillEngine) handleUserFills(userFills *models.UserFillsMessage) {
+	manager := engine.manager
+	if !strings.EqualFold(userFills.Data.User, manager.CopyAddress) {
+		return
+	}
+	var fresh []models.UserFill
+	for _, fill := range userFills.Data.Fills {
+		if _, seen := engine.seenTids[fill.Tid]; seen {
+			continue
+		}
+		markUsed(engine.seenTids, fill.Tid, manager.stateRetention)
+		if !userFills.Data.IsSnapshot {
+			engine.recordFillPx(fill)
+			fresh = append(fresh, fill)
+		}
+	}
+	if userFills.Data.IsSnapshot {
+		logger.LogInfof("[Fills] copy snapshot of %d fills => marked seen", len(userFills.Data.Fills))
+		return
+	}
+	if !engine.enabled || !manager.IsReady() || len(fresh) == 0 {
+		return
+	}
+	if manager.EnginePaused(EngineFill) {
+		logger.LogWarnf("[Fills] paste engine paused => skipping %d copy fills", len(fresh))
+		return
+	}
+	orders := engine.fillsToOrders(fresh)
+	if len(orders) > 0 {
+		manager.IocEngine.SendIocOrders(orders)
+	}
+}
+
+// recordFillPx adds fill to the fills of its order and wakes AvgPx.
+func (engine *FillEngine) recordFillPx(fill models.UserFill) {
+	engine.pxMu.Lock()
+	defer engine.pxMu.Unlock()
+	now := time.Now()
+	for oid, fills := range engine.byOid {
+		if now.Sub(fills.seenAt) > time.Minute {
+			delete(engine.byOid, oid)
+		}
+	}
+	fills := engine.oidFillsLocked(int64(fill.Oid))
+	fills.notional += fill.Px * fill.Sz
+	fills.size += fill.Sz
+	if filledAt := time.UnixMilli(fill.Time); filledAt.After(fills.filledAt) {
+		fills.filledAt = filledAt
+	}
+	select {
+	case <-fills.arrived:
+	default:
+		close(fills.arrived)
+	}
+}
+
+func (engine *FillEngine) oidFillsLocked(oid int64) *oidFills {
+	fills, ok := engine.byOid[oid]
+	if !ok {
+		fills = &oidFills{seenAt: time.Now(), arrived: make(chan struct{})}
+		engine.byOid[oid] = fills
+	}
+	return fills
+}
+
+// AvgPx returns the average price of the copy fills of oid, waiting up to wait for the
+// first of them.
+func (engine *FillEngine) AvgPx(oid int64, wait time.Duration) (float64, bool) {
+	engine.pxMu.Lock()
+	fills := engine.oidFillsLocked(oid)
+	engine.pxMu.Unlock()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-fills.arrived:
+	case <-timer.C:
+		return 0, false
+	}
+	engine.pxMu.Lock()
+	defer engine.pxMu.Unlock()
+	if fills.size <= 0 {
+		return 0, false
+	}
+	return fills.notional / fills.size, true
+}
+
+// Filled returns the size, average price and latest fill time of the copy fills of oid
+// seen so far.
+func (engine *FillEngine) Filled(oid int64) (size, avgPx float64, filledAt time.Time, ok bool) {
+	engine.pxMu.Lock()
+	defer engine.pxMu.Unlock()
+	fills, found := engine.byOid[oid]
+	if !found || fills.size <= 0 {
+		return 0, 0, time.Time{}, false
+	}
+	return fills.size, fills.notional / fills.size, fills.filledAt, true
+}
+
+// fillsToOrders nets the fills per coin into one scaled paste IOC order, priced off the
+// copy VWAP.
+func (engine *FillEngine) fillsToOrders(fills []models.UserFill) []hl.Order {
+	manager := engine.manager
+	type netFill struct {
+		szi      float64
+		notional float64
+		size     float64
+		lastTid  int64
+		filledAt time.Time
+	}
+	byCoin := make(map[string]*netFill)
+	var coins []string
+	for _, fill := range fills {
+		if !manager.IsEnabledCoin(fill.Coin) || manager.TriggerEngine.Mirrors(int64(fill.Oid)) {
+			continue
+		}
+		logger.LogFields("info", "copy "+logger.FormatFill(fill), utils.FillFields(fill))
+		net, ok := byCoin[fill.Coin]
+		if !ok {
+			net = &netFill{}
+			byCoin[fill.Coin] = net
+			coins = append(coins, fill.Coin)
+		}
+		net.szi += sideSign(fill.Side) * fill.Sz
+		net.notional += fill.Px * fill.Sz
+		net.size += fill.Sz
+		net.lastTid = fill.Tid
+		if filledAt := time.UnixMilli(fill.Time); filledAt.After(net.filledAt) {
+			net.filledAt = filledAt
+		}
+	}
+
+	var orders []hl.Order
+	for _, coin := range coins {
+		net := byCoin[coin]
+		side := "B"
+		if net.szi < 0 {
+			side = "A"
+		}
+		copyOrder := hl.Order{Coin: coin, Side: side, Sz: math.Abs(net.szi)}
+		sz := hl.SizeToFloat(manager.scaleFill(copyOrder, net.filledAt), manager.Decimals(coin))
+		if sz == 0 {
+			continue
+		}
+		vwap := net.notional / net.size
+		if Round2(sz*vwap) < minNotionalDiff {
+			continue
+		}
+		orders = append(orders, hl.Order{
+			Coin:      coin,
+			Side:      side,
+			Sz:        sz,
+			LimitPx:   vwap,
+			Cloid:     manager.NewPasteCloid(cloid.EngineFill, primaryLeader, uint64(net.lastTid)),
+			Tif:       hl.TifFrontendMarket,
+			OrderType: hl.TifFrontendMarket,
+		})
+	}
+	return orders
+}
+
+// SubscribeFillStreams subscribes the primary copy account's userFills. They are needed
+// even with the fill engine disabled, to price the IOC engine's mirrors.
+func (manager *Manager) SubscribeFillStreams(connection *websocket.Conn) error {
+	userCoin := models.SubscriptionPayload{User: manager.CopyAddress}
+	return connection.WriteJSON(models.NewSubcriptionRequest("userFills", userCoin))
+}
+
+func (manager *Manager) handleUserFillsPayload(rawData []byte) {
+	var userFills *models.UserFillsMessage
+	if err := json.Unmarshal(rawData, &userFills); err != nil {
+		logger.LogErrorf("failed to parse copy userFills: %v", err)
+		return
+	}
+	manager.UserFillsChan <- userFills
+}
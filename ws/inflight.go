@@ -0,0 +1,160 @@
+package ws
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	defaultInFlightTTL = 10 * time.Second
+	// clockWindow is how long the best server clock offset is kept before a later, more
+	// delayed frame may replace it.
+	clockWindow = time.Minute
+)
+
+// InFlightLedger tracks signed paste IOC size that has been submitted but is not yet
+// reflected in a paste webData2 snapshot. Entries are keyed by coin and cloid. Fill
+// times are kept in the server clock, which paste snapshots are compared in.
+type InFlightLedger struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*inFlightEntry
+
+	// clockOffset is server time minus local time, from the least delayed recent frame.
+	clockOffset   time.Duration
+	clockSyncedAt time.Time
+}
+
+type inFlightEntry struct {
+	Coin string
+	// Oid is the paste oid, once the order response has returned it.
+	Oid      int64
+	Szi      float64
+	SentAt   time.Time
+	FilledAt time.Time
+}
+
+func NewInFlightLedger(ttl time.Duration) *InFlightLedger {
+	if ttl <= 0 {
+		ttl = defaultInFlightTTL
+	}
+	return &InFlightLedger{
+		ttl:     ttl,
+		entries: make(map[string]*inFlightEntry),
+	}
+}
+
+func inFlightKey(coin, cloid string) string {
+	return coin + ":" + cloid
+}
+
+// Submit records a signed size as on its way to the paste account.
+func (l *InFlightLedger) Submit(coin, cloid string, szi float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[inFlightKey(coin, cloid)] = &inFlightEntry{
+		Coin:   coin,
+		Szi:    szi,
+		SentAt: time.Now(),
+	}
+}
+
+// SyncClock records the serverTime of a paste frame received just now. A frame's
+// serverTime trails the server by its delay, so the largest offset seen within
+// clockWindow is the closest estimate.
+func (l *InFlightLedger) SyncClock(serverTime time.Time) {
+	now := time.Now()
+	offset := serverTime.Sub(now)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.clockSyncedAt.IsZero() || offset > l.clockOffset || now.Sub(l.clockSyncedAt) > clockWindow {
+		l.clockOffset = offset
+		l.clockSyncedAt = now
+	}
+}
+
+// ServerNow is the current server time as estimated from the paste frames, or the local
+// time before the first one.
+func (l *InFlightLedger) ServerNow() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Now().Add(l.clockOffset)
+}
+
+// Settle shrinks an entry to the size the exchange actually filled. An entry with
+// nothing filled is dropped, a filled one stays in flight until a paste snapshot at or
+// after filledAt, a server time, is observed.
+func (l *InFlightLedger) Settle(coin, cloid string, filledSz float64, filledAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := inFlightKey(coin, cloid)
+	entry, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	if filledSz <= 1e-9 {
+		delete(l.entries, key)
+		return
+	}
+	entry.Szi = math.Copysign(math.Min(filledSz, math.Abs(entry.Szi)), entry.Szi)
+	if filledAt.After(entry.FilledAt) {
+		entry.FilledAt = filledAt
+	}
+}
+
+// Bind records the paste oid the exchange gave an entry's order.
+func (l *InFlightLedger) Bind(coin, cloid string, oid int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.entries[inFlightKey(coin, cloid)]; ok && oid != 0 {
+		entry.Oid = oid
+	}
+}
+
+// Claims reports whether an order update for coin, cloid and oid may be this ledger's:
+// the entry is in flight and either has no oid yet or has this one. Paste accounts
+// mirroring the same copy order mint the same cloid, so the oid tells them apart.
+func (l *InFlightLedger) Claims(coin, cloid string, oid int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[inFlightKey(coin, cloid)]
+	return ok && (entry.Oid == 0 || entry.Oid == oid)
+}
+
+func (l *InFlightLedger) Has(coin, cloid string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.entries[inFlightKey(coin, cloid)]
+	return ok
+}
+
+// Observe drops fills already contained in a paste snapshot taken at chTime, and
+// expires entries that were never settled.
+func (l *InFlightLedger) Observe(chTime time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, entry := range l.entries {
+		if !entry.FilledAt.IsZero() && !chTime.Before(entry.FilledAt) {
+			delete(l.entries, key)
+			continue
+		}
+		if time.Since(entry.SentAt) > l.ttl {
+			logger.LogWarnf("[InFlight] paste expired %s szi=%.6f after %v", entry.Coin, entry.Szi, l.ttl)
+			delete(l.entries, key)
+		}
+	}
+}
+
+// Pending returns the signed size in flight for coin.
+func (l *InFlightLedger) Pending(coin string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var total float64
+	for _, entry := range l.entries {
+		if entry.Coin == coin {
+			total += entry.Szi
+		}
+	}
+	return total
+}
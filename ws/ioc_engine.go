@@ -2,15 +2,21 @@ package ws
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"time"
 
 	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
 	"github.com/itay747/hyperformance/models"
+	"github.com/itay747/hyperformance/store"
+	"github.com/itay747/hyperformance/utils"
 )
 
 var (
-	minNotionalDiff = 20.0
+	minNotionalDiff               = 20.0
+	defaultReconcileEvery         = 5 * time.Second
+	defaultReconcileConfirmations = 3
 )
 
 type IocEngine struct {
@@ -20,14 +26,39 @@ type IocEngine struct {
 	enabled              bool
 	ctx                  context.Context
 	startupReconcileDone bool
+
+	// continuous reconcile loop state
+	reconcileLoop          bool
+	reconcileEvery         time.Duration
+	reconcileConfirmations int
+	lastReconcile          time.Time
+	lastDriftChTime        time.Time
+	lastSendPasteChTime    time.Time
+	driftStreaks           map[string]int
 }
 
 func NewIocEngine(ctx context.Context, m *Manager, enabled bool) *IocEngine {
+	reconcileEvery := defaultReconcileEvery
+	reconcileConfirmations := defaultReconcileConfirmations
+	reconcileLoop := true
+	if m.Config != nil {
+		if m.Config.ReconcileIntervalMs > 0 {
+			reconcileEvery = time.Duration(m.Config.ReconcileIntervalMs) * time.Millisecond
+		}
+		if m.Config.ReconcileConfirmations > 0 {
+			reconcileConfirmations = m.Config.ReconcileConfirmations
+		}
+		reconcileLoop = !m.Config.DisableReconcileLoop
+	}
 	return &IocEngine{
-		manager:              m,
-		enabled:              enabled,
-		ctx:                  ctx,
-		startupReconcileDone: false,
+		manager:                m,
+		enabled:                enabled,
+		ctx:                    ctx,
+		startupReconcileDone:   false,
+		reconcileLoop:          reconcileLoop,
+		reconcileEvery:         reconcileEvery,
+		reconcileConfirmations: reconcileConfirmations,
+		driftStreaks:           make(map[string]int),
 	}
 }
 
@@ -35,6 +66,7 @@ func (r *IocEngine) Start(
 	ctx context.Context,
 	copyWd2Stream <-chan *models.WebData2Message,
 	pasteWd2Stream <-chan *models.WebData2Message,
+	leaderWd2Stream <-chan *models.WebData2Message,
 	orderUpdatesChan <-chan *models.OrderMessage,
 ) {
 	go func() {
@@ -47,6 +79,10 @@ func (r *IocEngine) Start(
 				if !r.startupReconcileDone && r.enabled && r.manager.IsReady() && r.localPastePositions != nil {
 					r.handleIocReconcile(copyWd2, r.localPastePositions)
 					r.startupReconcileDone = true
+					r.lastReconcile = time.Now()
+					r.lastSendPasteChTime = r.manager.PasteWd2.ClearinghouseTime()
+				} else if r.startupReconcileDone {
+					r.handleReconcileLoop()
 				}
 
 			case pasteWd2 := <-pasteWd2Stream:
@@ -54,6 +90,16 @@ func (r *IocEngine) Start(
 					r.localPastePositions = pasteWd2.PositionsByCoin()
 					r.lastPasteWd2Rebase = time.Now()
 				}
+				r.manager.IocInFlight.Observe(pasteWd2.ClearinghouseTime())
+				if r.startupReconcileDone {
+					r.handleReconcileLoop()
+				}
+			case <-leaderWd2Stream:
+				// a secondary leader moved the target, which the startup reconcile
+				// will pick up if it hasn't run yet
+				if r.startupReconcileDone {
+					r.handleReconcileLoop()
+				}
 			case orderUpdate := <-orderUpdatesChan:
 				if r.manager.IsReady() {
 					r.handleOrderUpdates(orderUpdate)
@@ -64,15 +110,89 @@ func (r *IocEngine) Start(
 	}()
 }
 
+// handleReconcileLoop recomputes the reconcile orders against the latest copy, leader and
+// paste snapshots. A coin is only traded once its drift has been seen on reconcileConfirmations
+// consecutive clearinghouse times, and never more often than reconcileEvery.
+func (r *IocEngine) handleReconcileLoop() {
+	if !r.enabled || !r.reconcileLoop || !r.manager.IsReady() {
+		return
+	}
+	copyWd2 := r.manager.CopyWd2
+	pasteWd2 := r.manager.PasteWd2
+	if copyWd2 == nil || pasteWd2 == nil {
+		return
+	}
+	chTime := pasteWd2.ClearinghouseTime()
+	if copyWd2.ClearinghouseTime().After(chTime) {
+		chTime = copyWd2.ClearinghouseTime()
+	}
+	for _, leader := range r.manager.Leaders[1:] {
+		if leaderWd2 := leader.Wd2; leaderWd2 != nil && leaderWd2.ClearinghouseTime().After(chTime) {
+			chTime = leaderWd2.ClearinghouseTime()
+		}
+	}
+	if !chTime.After(r.lastDriftChTime) {
+		return
+	}
+	r.lastDriftChTime = chTime
+	// A paste snapshot taken before our last send can't reflect it yet, so any
+	// drift it shows is already on its way.
+	if !pasteWd2.ClearinghouseTime().After(r.lastSendPasteChTime) {
+		return
+	}
+
+	r.manager.IocInFlight.Observe(pasteWd2.ClearinghouseTime())
+	drift := r.manager.GetIocReconcileOrders(r.manager.TargetPositions(), pasteWd2.PositionsByCoin(), false, true)
+	drifting := make(map[string]bool, len(drift))
+	for _, order := range drift {
+		drifting[order.Coin] = true
+	}
+	for coin := range r.driftStreaks {
+		if !drifting[coin] {
+			delete(r.driftStreaks, coin)
+		}
+	}
+	var confirmed []hl.Order
+	for _, order := range drift {
+		r.driftStreaks[order.Coin]++
+		if r.driftStreaks[order.Coin] >= r.reconcileConfirmations {
+			confirmed = append(confirmed, order)
+		}
+	}
+	if len(confirmed) == 0 || time.Since(r.lastReconcile) < r.reconcileEvery {
+		return
+	}
+	if !r.manager.markPasteWd2Reconciled() {
+		return
+	}
+	for _, order := range confirmed {
+		delete(r.driftStreaks, order.Coin)
+	}
+	r.lastReconcile = time.Now()
+	r.lastSendPasteChTime = pasteWd2.ClearinghouseTime()
+	logger.LogInfof("[IOC] paste reconcile loop => %d orders after %d confirmations", len(confirmed), r.reconcileConfirmations)
+	r.SendIocOrders(confirmed)
+}
+
 func (r *IocEngine) handleOrderUpdates(orderUpdates *models.OrderMessage) {
 	//logger.LogInfof("Received order updates: %#+v", orderUpdates)
 	ordersOut := make([]hl.Order, 0)
+	var copyOids []int64
 
 	byCoin := make(map[string][]models.OrderUpdate)
 	for _, updateEntry := range orderUpdates.Data {
 		if !r.manager.IsEnabledCoin(updateEntry.Order.Coin) {
 			continue
 		}
+		if r.manager.isPasteOrderUpdate(updateEntry) {
+			r.handlePasteOrderUpdate(updateEntry)
+			continue
+		}
+		// The fill engine mirrors copy fills from userFills instead, and mirrored triggers
+		// fire on paste by themselves
+		if r.manager.FillEngine.enabled || r.manager.TriggerEngine.Mirrors(updateEntry.Order.Oid) {
+			continue
+		}
 		byCoin[updateEntry.Order.Coin] = append(byCoin[updateEntry.Order.Coin], updateEntry)
 	}
 	for _, grouped := range byCoin {
@@ -96,11 +216,13 @@ func (r *IocEngine) handleOrderUpdates(orderUpdates *models.OrderMessage) {
 					// only partially filled (therefore cancelled)
 					// or fully filled COPY order's from here
 					filledSz := order.OrigSz - order.Sz
+					filled := hl.Order{Coin: order.Coin, Side: order.Side, Sz: filledSz}
+					pasteSz := hl.SizeToFloat(r.manager.scaleFill(filled, time.UnixMilli(nextUpdate.StatusTimestamp)), r.manager.Decimals(order.Coin))
 					pasteOrder := hl.Order{
 						Coin:       order.Coin,
-						Sz:         filledSz,
+						Sz:         pasteSz,
 						Side:       order.Side,
-						Cloid:      r.manager.NewPasteIocCloid(order.Oid),
+						Cloid:      r.manager.NewPasteCloid(cloid.EngineIoc, primaryLeader, uint64(order.Oid)),
 						ReduceOnly: order.ReduceOnly,
 						Tif:        hl.TifFrontendMarket,
 						OrderType:  hl.TifFrontendMarket,
@@ -113,7 +235,8 @@ func (r *IocEngine) handleOrderUpdates(orderUpdates *models.OrderMessage) {
 					}
 
 					ordersOut = append(ordersOut, pasteOrder)
-					logger.LogInfof("\n%s\n%s", logger.FormatCopyOrder(order), logger.FormatPasteOrder(pasteOrder))
+					copyOids = append(copyOids, order.Oid)
+					logger.LogFields("info", fmt.Sprintf("\n%s\n%s", logger.FormatCopyOrder(order), logger.FormatPasteOrder(pasteOrder)), utils.OrderFields(pasteOrder))
 
 				}
 			}
@@ -122,11 +245,24 @@ func (r *IocEngine) handleOrderUpdates(orderUpdates *models.OrderMessage) {
 	}
 
 	if len(ordersOut) > 0 {
-		r.SendIocOrders(ordersOut)
+		go r.sendAtFillPx(ordersOut, copyOids)
 	}
 
 }
 
+// sendAtFillPx prices each mirror off the average price of its copy order's fills, so
+// iocPrice bounds the slippage from the leader's price rather than the mid. Mirrors whose
+// fills haven't arrived within fillPxWait are sent priced off the mid.
+func (r *IocEngine) sendAtFillPx(orders []hl.Order, copyOids []int64) {
+	deadline := time.Now().Add(fillPxWait)
+	for i := range orders {
+		if avgPx, ok := r.manager.FillEngine.AvgPx(copyOids[i], time.Until(deadline)); ok {
+			orders[i].LimitPx = avgPx
+		}
+	}
+	r.SendIocOrders(orders)
+}
+
 func (r *IocEngine) IocOrdersToRequests(orders []hl.Order) []hl.OrderRequest {
 	var requests []hl.OrderRequest
 	for _, order := range orders {
@@ -136,7 +272,12 @@ func (r *IocEngine) IocOrdersToRequests(orders []hl.Order) []hl.OrderRequest {
 		}
 
 		if order.Tif == hl.TifFrontendMarket || order.OrderType == hl.TifFrontendMarket {
-			order.LimitPx = r.manager.getMarketPrice(order)
+			limitPx, sz, ok := r.manager.iocPrice(order)
+			if !ok {
+				continue
+			}
+			order.LimitPx = limitPx
+			order.Sz = sz
 			order.Tif = hl.TifFrontendMarket
 		} else {
 			scaled := r.manager.scaleSize(order)
@@ -164,7 +305,13 @@ func (r *IocEngine) IocOrdersToRequests(orders []hl.Order) []hl.OrderRequest {
 	return requests
 }
 func (r *IocEngine) handlePasteOrderUpdate(orderUpdate models.OrderUpdate) {
-	logger.LogInfof("paste %s", logger.FormatOrderUpdate(orderUpdate))
+	logger.LogFields("info", "paste "+logger.FormatOrderUpdate(orderUpdate), utils.OrderFields(orderUpdate.Order))
+	if orderUpdate.Status == "open" {
+		return
+	}
+	order := orderUpdate.Order
+	filledSz := order.OrigSz - order.Sz
+	r.manager.IocInFlight.Settle(order.Coin, order.Cloid, filledSz, time.UnixMilli(orderUpdate.StatusTimestamp))
 }
 
 func (r *IocEngine) handleIocReconcile(copyWd2 *models.WebData2Message, pastePositionsModelled map[string]models.Position) {
@@ -188,7 +335,7 @@ func (r *IocEngine) handleIocReconcile(copyWd2 *models.WebData2Message, pastePos
 	// 	}
 
 	// } else {
-	orders := r.manager.GetIocReconcileOrders(copyWd2.PositionsByCoin(), pastePositionsModelled, true, false)
+	orders := r.manager.GetIocReconcileOrders(r.manager.TargetPositions(), pastePositionsModelled, false, false)
 	// We now update pastePositionsByCoin immediately with the new sizes, assuming orders will succeed
 	for _, order := range orders {
 		position := pastePositionsModelled[order.Coin]
@@ -202,28 +349,81 @@ func (r *IocEngine) handleIocReconcile(copyWd2 *models.WebData2Message, pastePos
 	r.SendIocOrders(orders)
 
 }
+
+// ReconcileRequests returns the IOC requests a reconcile would send for the paste
+// account's drift from the copy target right now, after the risk gate, without sending
+// them.
+func (manager *Manager) ReconcileRequests() []hl.OrderRequest {
+	orders := manager.GetIocReconcileOrders(manager.TargetPositions(), manager.PasteWd2.PositionsByCoin(), false, false)
+	return manager.RiskGate.Filter(manager.IocEngine.IocOrdersToRequests(orders))
+}
+
 func (r *IocEngine) SendIocOrders(orders []hl.Order) {
-	requests := r.IocOrdersToRequests(orders)
+	if r.manager.Halted() {
+		logger.LogWarnf("[IOC] paste breaker tripped => dropping %d orders", len(orders))
+		return
+	}
+	if r.manager.EnginePaused(EngineIoc) {
+		logger.LogWarnf("[IOC] paste engine paused => dropping %d orders", len(orders))
+		return
+	}
+	requests := r.manager.RiskGate.Filter(r.IocOrdersToRequests(orders))
 	if len(requests) == 0 {
 		logger.LogInfo("[IOC] paste Reconcile produced no valid request => skipping")
 		return
 	}
-	resp, err := r.manager.Client.BulkOrders(requests, hl.GroupingNa)
+	inFlight := r.manager.IocInFlight
+	for i := range requests {
+		// reconcile orders get their cloid only once they pass the gate
+		if requests[i].Cloid == "" {
+			requests[i].Cloid = r.manager.NewReconcileCloid(uint64(r.manager.CopyWd2.Data.ClearinghouseState.Time))
+		}
+		req := requests[i]
+		szi := req.Sz
+		if !req.IsBuy {
+			szi = -szi
+		}
+		inFlight.Submit(req.Coin, req.Cloid, szi)
+	}
+	r.manager.syncLeverage(requests)
+	resp, err := r.manager.bulkOrders(EngineIoc, requests, hl.GroupingNa)
 	if err != nil {
 		logger.LogErrorf("[IOC] paste BulkOrders error => %v", err)
+		settleRejected(inFlight, requests)
 		return
 	}
 	if resp.Status != "ok" {
 		logger.LogErrorf("[IOC] paste BulkOrders returned status %q => skipping", resp.Status)
+		settleRejected(inFlight, requests)
 		return
 	}
+	now := inFlight.ServerNow()
 	for i, st := range resp.Response.Data.Statuses {
+		if i >= len(requests) {
+			break
+		}
 		isBuy := requests[i].IsBuy
 		side := "LONG"
 		if !isBuy {
 			side = "SHORT"
 		}
-		logger.LogInfof("[IOC] paste %s %s %v", side, requests[i].Coin, st.Filled.TotalSz)
+		if st.Error != "" {
+			logger.LogFields("error", fmt.Sprintf("[IOC] paste %s %s rejected => %s", side, requests[i].Coin, st.Error), utils.RequestFields(requests[i]))
+			inFlight.Settle(requests[i].Coin, requests[i].Cloid, 0, now)
+			continue
+		}
+		inFlight.Bind(requests[i].Coin, requests[i].Cloid, int64(st.Filled.OrderID))
+		inFlight.Settle(requests[i].Coin, requests[i].Cloid, st.Filled.TotalSz, now)
+		filled := utils.RequestFields(requests[i])
+		filled.Size, filled.Px = st.Filled.TotalSz, st.Filled.AvgPx
+		logger.LogFields("info", fmt.Sprintf("[IOC] paste %s %s %v", side, requests[i].Coin, st.Filled.TotalSz), filled)
+	}
+}
+
+func settleRejected(inFlight *InFlightLedger, requests []hl.OrderRequest) {
+	now := inFlight.ServerNow()
+	for _, req := range requests {
+		inFlight.Settle(req.Coin, req.Cloid, 0, now)
 	}
 }
 func sideSign(side string) float64 {
@@ -237,15 +437,12 @@ func sideSign(side string) float64 {
 // Returns no orders if no diff exists, any orders mean a notional diff was found.
 func (manager *Manager) GetIocReconcileOrders(copyPosMap map[string]models.Position, pastePosMap map[string]models.Position, scale bool, bypassCheck bool) []hl.Order {
 
-	copyPosKey := manager.CopyWd2.PositionsToKey()
-
-	logger.LogInfof("copy: %s", copyPosKey)
 	var newOrders []hl.Order
 
 	if len(copyPosMap) == 0 && len(pastePosMap) == 0 {
 		return newOrders
 	}
-	for _, symbol := range manager.AllowedSymbols {
+	for _, symbol := range manager.AllowedSymbols() {
 		copyPos := copyPosMap[symbol]
 		pastePos := pastePosMap[symbol]
 
@@ -257,10 +454,6 @@ func (manager *Manager) GetIocReconcileOrders(copyPosMap map[string]models.Posit
 			continue
 		}
 
-		sideOfCopy := "B"
-		if copyPos.Szi < 0 {
-			sideOfCopy = "A"
-		}
 		decimals := manager.Decimals(symbol)
 		// copySzi and copyNotional are both scaled from here until end of func
 		// Variable ending with Szi are signed
@@ -268,9 +461,10 @@ func (manager *Manager) GetIocReconcileOrders(copyPosMap map[string]models.Posit
 		// Variables ending with Sz are unsigned (always +)
 		copySzi := copyPos.Szi
 		if scale {
-			copySzi = manager.scaleSize(hl.Order{Coin: symbol, Side: sideOfCopy, Sz: copyPos.Szi})
+			copySzi = RoundToPrecision(manager.targetSzi(symbol, copyPos.Szi), decimals)
 		}
-		pasteSzi := pastePos.Szi
+		// Size already sent but not yet in the paste snapshot counts as held
+		pasteSzi := pastePos.Szi + manager.IocInFlight.Pending(symbol)
 
 		copyNotional := RoundToPrecision(math.Abs(copySzi)*midPrice, 2)
 		pasteNotional := RoundToPrecision(math.Abs(pasteSzi)*midPrice, 2)
@@ -282,10 +476,10 @@ func (manager *Manager) GetIocReconcileOrders(copyPosMap map[string]models.Posit
 		}
 
 		// Build a base order with default fields we might change
+		// The cloid is minted by SendIocOrders, as most drift passes send nothing
 		baseOrder := hl.Order{
-			Coin:  symbol,
-			Tif:   hl.TifFrontendMarket,
-			Cloid: manager.NewPasteIocCloid(manager.CopyWd2.Data.ClearinghouseState.Time),
+			Coin: symbol,
+			Tif:  hl.TifFrontendMarket,
 		}
 
 		var finalSide string
@@ -355,21 +549,49 @@ func (manager *Manager) GetIocReconcileOrders(copyPosMap map[string]models.Posit
 		}
 		baseOrder.Side = finalSide
 		baseOrder.Sz = finalDiffSz
-		pasteWd2Time := manager.PasteWd2.Data.ClearinghouseState.Time
-		if _, ok := manager.UsedPasteWd2[pasteWd2Time]; !ok {
-			manager.UsedPasteWd2[pasteWd2Time] = time.Now()
-			newOrders = append(newOrders, baseOrder)
-		} else {
-			logger.LogWarnf("paste Tried reconciling on prev reconciled wd2 clearinghouse timestamp: %v", pasteWd2Time)
-		}
+		newOrders = append(newOrders, baseOrder)
 	}
 
-	if len(newOrders) == 0 {
+	if len(newOrders) == 0 || bypassCheck {
 		return newOrders
 	}
+	if !manager.markPasteWd2Reconciled() {
+		return nil
+	}
+	logger.LogInfof("copy: %s", manager.CopyWd2.PositionsToKey())
 	return newOrders
 }
 
+// markPasteWd2Reconciled records the current paste clearinghouse time as reconciled.
+// Returns false if it was already used, so the same snapshot never drives two reconciles.
+func (manager *Manager) markPasteWd2Reconciled() bool {
+	pasteWd2Time := manager.PasteWd2.Data.ClearinghouseState.Time
+	if _, ok := manager.UsedPasteWd2[pasteWd2Time]; ok {
+		logger.LogWarnf("paste Tried reconciling on prev reconciled wd2 clearinghouse timestamp: %v", pasteWd2Time)
+		return false
+	}
+	markUsed(manager.UsedPasteWd2, pasteWd2Time, manager.stateRetention)
+	manager.journalTime(store.KindUsedPasteWd2, pasteWd2Time)
+	return true
+}
+
+// maxTradeSz is the paste account's max trade size for the order's side, from activeAssetData.
+func (manager *Manager) maxTradeSz(order hl.Order) (float64, bool) {
+	value, ok := manager.AssetDetailsStore.Load(manager.PasteAddress + ":" + order.Coin)
+	if !ok {
+		return 0, false
+	}
+	details := value.(models.AssetDetails)
+	i := 0
+	if order.Side == "A" {
+		i = 1
+	}
+	if len(details.MaxTradeAmounts) <= i {
+		return 0, false
+	}
+	return details.MaxTradeAmounts[i], true
+}
+
 func (manager *Manager) HasMargin(iocOrder hl.Order) bool {
 	// We assume all IOC orders are placed on the Paste side
 	// so we retrieve AssetDetails for manager.PasteAddress + ":" + iocOrder.Coin
@@ -386,8 +608,13 @@ func (manager *Manager) HasMargin(iocOrder hl.Order) bool {
 	if iocOrder.Side == "A" {
 		i = 1
 	}
+	if len(details.AvailableToTrade) <= i {
+		logger.LogWarnf("[HasMargin] No availableToTrade => coin=%s key=%s", iocOrder.Coin, key)
+		return false
+	}
+	// availableToTrade is USD margin, which buys leverage times its notional
 	marginAvailable := details.AvailableToTrade[i]
-	notionalAvailable := Round2(marginAvailable * details.LeverageValue * midPx)
+	notionalAvailable := Round2(marginAvailable * details.LeverageValue)
 	if notionalAvailable < orderNotional {
 		logger.LogWarnf("[HasMargin] Not enough margin => wanted notional=$%.2f available=$%.2f coin=%s",
 			orderNotional, notionalAvailable, iocOrder.Coin)
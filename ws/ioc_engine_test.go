@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/itay747/hyperformance/cloid"
+)
+
+func TestReconcileMintsCloidsOnlyWhenSent(t *testing.T) {
+	manager, exchange := newTestManager(t, nil)
+	feed(t, manager, 1000, 100000, map[string]float64{"BTC": 0.1}, nil)
+
+	for range 3 {
+		drift := manager.GetIocReconcileOrders(manager.TargetPositions(), manager.PasteWd2.PositionsByCoin(), false, true)
+		if len(drift) != 1 || drift[0].Cloid != "" {
+			t.Fatalf("drift = %+v, want one BTC order without a cloid", drift)
+		}
+	}
+	if seq := manager.reconcileSeq.Load(); seq != 0 {
+		t.Fatalf("drift passes used %d reconcile sequences, want 0", seq)
+	}
+
+	drift := manager.GetIocReconcileOrders(manager.TargetPositions(), manager.PasteWd2.PositionsByCoin(), false, true)
+	manager.IocEngine.SendIocOrders(drift)
+	sent := exchange.sentOrders()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d orders, want 1", len(sent))
+	}
+	id, ok := cloid.Decode(sent[0].Cloid)
+	if !ok || id.Engine != cloid.EngineReconcile || id.Seq != 1 || id.Source != 1000 {
+		t.Errorf("sent cloid %s, want reconcile#1000.1", cloid.Label(sent[0].Cloid))
+	}
+	if !sent[0].IsBuy || sent[0].Sz != 0.1 {
+		t.Errorf("sent buy=%v sz=%v, want a 0.1 BTC buy", sent[0].IsBuy, sent[0].Sz)
+	}
+	// the fill counts as held until a paste snapshot contains it
+	if pending := manager.IocInFlight.Pending("BTC"); pending != 0.1 {
+		t.Errorf("in flight after a filled response = %v, want 0.1", pending)
+	}
+	if drift := manager.GetIocReconcileOrders(manager.TargetPositions(), manager.PasteWd2.PositionsByCoin(), false, true); len(drift) != 0 {
+		t.Errorf("drift with the fill in flight = %+v, want none", drift)
+	}
+	manager.IocInFlight.Observe(manager.IocInFlight.ServerNow().Add(time.Millisecond))
+	if pending := manager.IocInFlight.Pending("BTC"); pending != 0 {
+		t.Errorf("in flight after a later paste snapshot = %v, want 0", pending)
+	}
+}
@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+)
+
+// primaryLeader is the index of the primary copy account in Leaders. The engines mirror
+// its orders only; secondary leaders are followed through the position reconcile.
+const primaryLeader = 0
+
+// Leader is a copy account whose scaled positions are blended into the paste target.
+// Leaders[0] is always the primary copy account, whose chain is also Manager.CopyWd2.
+type Leader struct {
+	Address     string
+	Weight      float64
+	CoinRiskMap map[string]float64
+	// sharedCoins is set when CoinRiskMap is the top-level coins map, in which case the
+	// Manager's current weights apply instead; see leaderCoins.
+	sharedCoins bool
+
+	Wd2        *models.WebData2Message
+	lastChTime time.Time
+}
+
+// buildLeaders resolves the configured leaders, making sure the copy address leads the
+// list. Leaders without their own coins map share the top-level one.
+func buildLeaders(cfg *config.HyperformanceConfig) []*Leader {
+	copyAddress := strings.ToLower(cfg.CopyAddress)
+	if copyAddress == "" && len(cfg.Leaders) > 0 {
+		copyAddress = strings.ToLower(cfg.Leaders[0].Address)
+	}
+	leaders := []*Leader{{Address: copyAddress, Weight: 1, CoinRiskMap: cfg.CoinRiskMap, sharedCoins: true}}
+	for _, leaderConfig := range cfg.Leaders {
+		leader := &Leader{
+			Address:     strings.ToLower(leaderConfig.Address),
+			Weight:      leaderConfig.Weight,
+			CoinRiskMap: leaderConfig.Coins,
+		}
+		if leader.Weight <= 0 {
+			leader.Weight = 1
+		}
+		if leader.CoinRiskMap == nil {
+			leader.CoinRiskMap = cfg.CoinRiskMap
+			leader.sharedCoins = true
+		}
+		if leader.Address == copyAddress {
+			leaders[0] = leader
+			continue
+		}
+		leaders = append(leaders, leader)
+	}
+	return leaders
+}
+
+// leaderCoins is the coins map leader is sized with: the Manager's, as changed by the
+// control API, when it shares the top-level one.
+func (manager *Manager) leaderCoins(leader *Leader) map[string]float64 {
+	if leader.sharedCoins {
+		return manager.CoinRiskMap()
+	}
+	return leader.CoinRiskMap
+}
+
+func (manager *Manager) secondaryLeader(address string) *Leader {
+	for _, leader := range manager.Leaders[1:] {
+		if leader.Address == address {
+			return leader
+		}
+	}
+	return nil
+}
+
+func (manager *Manager) leadersReady() bool {
+	for _, leader := range manager.Leaders[1:] {
+		if leader.Wd2 == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SubscribeLeaderStreams subscribes a secondary leader's webData2. Secondary leaders are
+// followed through the position reconcile only, their order updates are not mirrored.
+func (manager *Manager) SubscribeLeaderStreams(connection *websocket.Conn, userAddress string) error {
+	userCoin := models.SubscriptionPayload{User: userAddress}
+	return connection.WriteJSON(models.NewSubcriptionRequest("webData2", userCoin))
+}
+
+func (manager *Manager) handleLeaderWd2(leader *Leader, wd2 *models.WebData2Message) {
+	if leader.lastChTime == wd2.ClearinghouseTime() {
+		return
+	}
+	leader.Wd2 = wd2.AddPrev(leader.Wd2)
+	leader.lastChTime = wd2.ClearinghouseTime()
+	manager.LeaderWd2Chan <- wd2
+}
+
+// scaleFactorFor is the paste/leader account value ratio times the leader's coin risk
+// and the paste account's own scale.
+func (manager *Manager) scaleFactorFor(leaderWd2 *models.WebData2Message, coinRiskMap map[string]float64, symbol string) float64 {
+	if leaderWd2 == nil || manager.PasteWd2 == nil {
+		return 0
+	}
+	leaderAccountValue := leaderWd2.AccountValue()
+	pasteAccountValue := manager.PasteWd2.AccountValue()
+	if leaderAccountValue == 0 || pasteAccountValue == 0 {
+		logger.LogErrorf("[scaleFactorFor] copy paste leaderAccountValue= $%v == 0 || pasteAccountValue= $%v == 0", leaderAccountValue, pasteAccountValue)
+		return 0
+	}
+	return pasteAccountValue / leaderAccountValue * coinRiskMap[symbol] * manager.PasteScale
+}
+
+// TargetPositions is the paste target: targetSzi for every enabled coin, with the
+// primary leader's current position. The result is already scaled, so pass it to
+// GetIocReconcileOrders with scale=false.
+func (manager *Manager) TargetPositions() map[string]models.Position {
+	targets := make(map[string]models.Position)
+	var primaryPositions map[string]models.Position
+	if primary := manager.Leaders[0]; primary.Wd2 != nil {
+		primaryPositions = primary.Wd2.PositionsByCoin()
+	}
+	for _, symbol := range manager.AllowedSymbols() {
+		szi := RoundToPrecision(manager.targetSzi(symbol, primaryPositions[symbol].Szi), manager.Decimals(symbol))
+		if szi == 0 {
+			continue
+		}
+		targets[symbol] = models.Position{
+			Coin:          symbol,
+			Szi:           szi,
+			PositionValue: math.Abs(szi) * manager.GetMidPrice(symbol),
+		}
+	}
+	return targets
+}
@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/itay747/hyperformance/config"
+)
+
+const testLeaderAddress = "0x3333333333333333333333333333333333333333"
+
+func TestSecondaryLeaderFeedsTheReconcileOnly(t *testing.T) {
+	manager, exchange := newTestManager(t, func(cfg *config.HyperformanceConfig) {
+		cfg.Leaders = []config.LeaderConfig{{Address: testLeaderAddress}}
+		cfg.ReconcileConfirmations = 1
+	})
+	manager.handleWebData2Payload(testWd2Frame(t, testLeaderAddress, 1000, 100000, nil))
+	feed(t, manager, 1000, 100000, nil, nil)
+	<-manager.LeaderWd2Chan
+
+	engine := manager.IocEngine
+	engine.startupReconcileDone = true
+	engine.handleReconcileLoop()
+	if sent := exchange.sentOrders(); len(sent) != 0 {
+		t.Fatalf("reconcile without drift sent %+v", sent)
+	}
+
+	manager.handleWebData2Payload(testWd2Frame(t, testLeaderAddress, 2000, 100000, map[string]float64{"BTC": 0.2}))
+	if n := len(manager.CopyWd2Chan); n != 0 {
+		t.Errorf("the secondary leader's snapshot reached CopyWd2Chan (%d queued), which the ALO and trigger engines mirror", n)
+	}
+	if n := len(manager.LeaderWd2Chan); n != 1 {
+		t.Fatalf("%d leader snapshots queued, want 1", n)
+	}
+	<-manager.LeaderWd2Chan
+	// only the leader's clearinghouse time moved, which must still run a reconcile pass
+	engine.handleReconcileLoop()
+	sent := exchange.sentOrders()
+	if len(sent) != 1 || sent[0].Coin != "BTC" || !sent[0].IsBuy {
+		t.Fatalf("reconcile after the leader bought sent %+v, want one BTC buy", sent)
+	}
+}
@@ -0,0 +1,110 @@
+package ws
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/itay747/hyperformance/models"
+)
+
+// leveragePendingTTL is how long an accepted update is trusted before the paste
+// activeAssetData shows it. After that it is sent again.
+var leveragePendingTTL = 10 * time.Second
+
+// LeverageSync mirrors the copy account's leverage and margin mode onto the paste account.
+// Ensure is called before orders on a coin are sent, so the paste side always trades
+// with the leader's latest setting, clamped to max_leverage.
+type LeverageSync struct {
+	mu          sync.Mutex
+	manager     *Manager
+	maxLeverage int
+	// pending holds updates the exchange accepted that the paste activeAssetData
+	// doesn't show yet, so they aren't sent twice.
+	pending map[string]pendingLeverage
+}
+
+type pendingLeverage struct {
+	setting leverageSetting
+	sentAt  time.Time
+}
+
+type leverageSetting struct {
+	IsCross bool
+	Value   int
+}
+
+func (s leverageSetting) String() string {
+	if s.IsCross {
+		return fmt.Sprintf("cross %dx", s.Value)
+	}
+	return fmt.Sprintf("isolated %dx", s.Value)
+}
+
+func NewLeverageSync(manager *Manager, maxLeverage int) *LeverageSync {
+	return &LeverageSync{
+		manager:     manager,
+		maxLeverage: maxLeverage,
+		pending:     make(map[string]pendingLeverage),
+	}
+}
+
+// Ensure updates the paste leverage on coin if the paste activeAssetData differs from
+// the copy side's, so a change made outside the bot is undone too. A failed update is
+// logged and retried on the next call.
+func (s *LeverageSync) Ensure(coin string) {
+	manager := s.manager
+	wanted, ok := s.setting(manager.CopyAddress, coin)
+	if !ok || wanted.Value <= 0 {
+		return
+	}
+	if s.maxLeverage > 0 && wanted.Value > s.maxLeverage {
+		wanted.Value = s.maxLeverage
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.setting(manager.PasteAddress, coin); ok && current == wanted {
+		delete(s.pending, coin)
+		return
+	}
+	if pending, ok := s.pending[coin]; ok && pending.setting == wanted && time.Since(pending.sentAt) < leveragePendingTTL {
+		return
+	}
+	resp, err := manager.Client.UpdateLeverage(coin, wanted.IsCross, wanted.Value)
+	if err != nil {
+		logger.LogErrorf("[LeverageSync] paste UpdateLeverage %s => %s failed: %v", coin, wanted, err)
+		return
+	}
+	if resp.Status != "ok" {
+		logger.LogErrorf("[LeverageSync] paste UpdateLeverage %s => %s returned status %q", coin, wanted, resp.Status)
+		return
+	}
+	logger.LogInfof("[LeverageSync] paste %s leverage => %s", coin, wanted)
+	s.pending[coin] = pendingLeverage{setting: wanted, sentAt: time.Now()}
+}
+
+// EnsureAll runs Ensure once for every distinct coin in coins.
+func (s *LeverageSync) EnsureAll(coins []string) {
+	seen := make(map[string]bool, len(coins))
+	for _, coin := range coins {
+		if seen[coin] {
+			continue
+		}
+		seen[coin] = true
+		s.Ensure(coin)
+	}
+}
+
+func (s *LeverageSync) setting(address, coin string) (leverageSetting, bool) {
+	value, ok := s.manager.AssetDetailsStore.Load(address + ":" + coin)
+	if !ok {
+		return leverageSetting{}, false
+	}
+	details := value.(models.AssetDetails)
+	return leverageSetting{
+		IsCross: details.LeverageType != "isolated",
+		Value:   int(math.Round(details.LeverageValue)),
+	}, true
+}
@@ -3,48 +3,86 @@ package ws
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"math"
-	"math/big"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
-	"github.com/bitfield/script"
+	"github.com/itay747/hyperformance/cloid"
 	"github.com/itay747/hyperformance/config"
 	"github.com/itay747/hyperformance/models"
+	"github.com/itay747/hyperformance/store"
 )
 
 // Manager is the main struct that manages the Hyperformance bot
 type Manager struct {
 	AddLogFunc func(address, message string)
 
-	Client *hl.Hyperliquid
+	Client Exchange
+	Config *config.HyperformanceConfig
+	Paper  *PaperExchange
+
+	Recorder    *Recorder
+	ReplayPath  string
+	ReplaySpeed float64
 
 	//ArchEngine ArchEngine
-	AloEngine *AloEngine
-	IocEngine *IocEngine
+	AloEngine  *AloEngine
+	IocEngine  *IocEngine
+	FillEngine *FillEngine
+	// TriggerEngine mirrors the copy account's stop and take profit orders.
+	TriggerEngine *TriggerEngine
 
 	CopyAddress  string
 	PasteAddress string
+	PasteScale   float64
+	Endpoint     string
+	Leaders      []*Leader
+
+	// Children are further paste accounts fed from this Manager's websocket.
+	Children []*Manager
+	isChild  bool
 
 	CopyWd2Chan  chan *models.WebData2Message
 	PasteWd2Chan chan *models.WebData2Message
+	// LeaderWd2Chan carries the secondary leaders' snapshots, which only the IOC
+	// engine's reconcile follows. CopyWd2Chan carries the primary leader's alone.
+	LeaderWd2Chan chan *models.WebData2Message
+	// frames carries every inbound frame, from the websocket or a PaperExchange, to
+	// the one goroutine that handles them. Children share it.
+	frames chan []byte
 
 	UsedPasteWd2       map[int64]time.Time
 	UsedCopyAloCreates map[int64]time.Time
 
+	// Store journals the dedup state when state_file is set. Children share it.
+	Store          *store.Store
+	stateRetention time.Duration
+
+	IocInFlight *InFlightLedger
+	// reconcileSeq numbers reconcile orders, which can share a source clearinghouse time.
+	reconcileSeq atomic.Uint32
+
+	// RiskGate checks every outbound paste order.
+	RiskGate *RiskGate
+	Breaker  *Breaker
+	// LeverageSync is nil unless sync_leverage is set.
+	LeverageSync *LeverageSync
+
 	CopyWd2  *models.WebData2Message
 	PasteWd2 *models.WebData2Message
 
 	OrderUpdatesChan chan *models.OrderMessage
+	UserFillsChan    chan *models.UserFillsMessage
 
 	L2BookSnapshotChan chan *models.L2BookSnapshotMessage
+	Books              *BookStore
 
-	AllowedSymbols         []string
 	MetaMap                map[string]hl.AssetInfo
 	AssetDetailsStore      sync.Map
 	AssetCtxStore          sync.Map
@@ -58,7 +96,17 @@ type Manager struct {
 	subNeeded              int32
 	subConfirmChan         chan struct{}
 	logStore               sync.Map
-	CoinRiskMap            map[string]float64
+	// streamSymbols are the coins enabled at startup, whose per-coin streams are
+	// subscribed. The control API can only enable these.
+	streamSymbols []string
+
+	// controlMu guards pausedEngines, allowedSymbols and coinRiskMap. The control API
+	// replaces the last two rather than changing them, so readers may keep what the
+	// accessors return.
+	controlMu      sync.Mutex
+	pausedEngines  map[string]bool
+	allowedSymbols []string
+	coinRiskMap    map[string]float64
 
 	i int64
 }
@@ -72,40 +120,131 @@ func (m *Manager) defaultAddLog(address, message string) {
 	}
 	store.(*models.RingBuffer).Push(line)
 }
-func loadInternalConfig() (*config.HyperformanceConfig, error) {
-	var loadedConfiguration config.HyperformanceConfig
-	configBytes, loadErr := script.File("../config.json").Bytes()
-	if loadErr != nil {
-		return nil, loadErr
-	}
-	_ = json.Unmarshal(configBytes, &loadedConfiguration)
-	return &loadedConfiguration, nil
+
+// ManagerOptions holds the command line switches that change how the Manager trades.
+type ManagerOptions struct {
+	// DryRun replaces the paste account with a local PaperExchange.
+	DryRun bool
+	// RecordPath, when set, appends every raw websocket frame to a recording.
+	RecordPath string
+	// ReplayPath, when set, feeds a recording instead of connecting. Implies DryRun.
+	ReplayPath  string
+	ReplaySpeed float64
+	// ReadOnly skips the startup policy, the state file and the breaker's unwinding, for
+	// one-shot commands that only read the accounts or act on them explicitly.
+	ReadOnly bool
 }
 
-// NewManager creates a new Manager instance
-func NewManager(ctx context.Context) *Manager {
+// NewManager creates a new Manager instance trading through the live Hyperliquid client,
+// or through a PaperExchange when opts.DryRun is set. managerConfig comes from
+// config.Load, already validated.
+func NewManager(ctx context.Context, managerConfig *config.HyperformanceConfig, opts ManagerOptions) *Manager {
+	dryRun := opts.DryRun || opts.ReplayPath != ""
+	if !dryRun && !opts.ReadOnly {
+		if err := managerConfig.RequireLive(); err != nil {
+			panic(err)
+		}
+	}
+	if opts.ReadOnly {
+		readOnlyConfig := *managerConfig
+		readOnlyConfig.StateFile = ""
+		readOnlyConfig.Breaker = config.BreakerConfig{}
+		managerConfig = &readOnlyConfig
+	}
+	var m *Manager
+	if dryRun {
+		m = newPaperManager(ctx, managerConfig)
+	} else {
+		m = newLiveManager(ctx, managerConfig, managerConfig.PasteAddress, managerConfig.SecretKey)
+	}
+	if !opts.ReadOnly {
+		if err := applyStartupPolicy(m.Client, managerConfig); err != nil {
+			panic(err)
+		}
+	}
+	// A simulated account starts empty, so only live runs keep state across restarts
+	if !dryRun && managerConfig.StateFile != "" {
+		stateStore, err := store.Open(managerConfig.StateFile, m.stateRetention)
+		if err != nil {
+			panic(err)
+		}
+		m.Store = stateStore
+		m.restoreState()
+	}
+	for _, account := range managerConfig.PasteAccounts {
+		childConfig := *managerConfig
+		childConfig.PasteAddress = account.Address
+		childConfig.SecretKey = account.SecretKey
+		childConfig.PasteAccounts = nil
+		if account.Coins != nil {
+			childConfig.CoinRiskMap = account.Coins
+		}
+		var child *Manager
+		if dryRun {
+			child = newPaperManager(ctx, &childConfig)
+		} else {
+			child = newLiveManager(ctx, &childConfig, account.Address, account.SecretKey)
+		}
+		if !opts.ReadOnly {
+			if err := applyStartupPolicy(child.Client, &childConfig); err != nil {
+				panic(err)
+			}
+		}
+		if account.Scale > 0 {
+			child.PasteScale = account.Scale
+		}
+		child.Store = m.Store
+		child.RiskGate.shareRateLimit(m.RiskGate)
+		child.isChild = true
+		child.frames = m.frames
+		child.restoreState()
+		m.Children = append(m.Children, child)
+	}
+	if opts.RecordPath != "" {
+		recorder, err := NewRecorder(opts.RecordPath)
+		if err != nil {
+			panic(err)
+		}
+		m.Recorder = recorder
+	}
+	m.ReplayPath = opts.ReplayPath
+	m.ReplaySpeed = opts.ReplaySpeed
+	return m
+}
 
-	botConfig, configErr := loadInternalConfig()
-	if configErr != nil {
-		panic(configErr)
+func newPaperManager(ctx context.Context, managerConfig *config.HyperformanceConfig) *Manager {
+	if managerConfig.PasteAddress == "" {
+		managerConfig.PasteAddress = paperAddress
 	}
-	managerConfig, configErr := config.LoadConfig()
-	if configErr != nil {
-		panic(configErr)
+	paper := NewPaperExchange(managerConfig)
+	m := NewManagerWithExchange(ctx, managerConfig, paper)
+	paper.manager = m
+	m.Paper = paper
+	return m
+}
+
+func newLiveManager(ctx context.Context, managerConfig *config.HyperformanceConfig, address, secretKey string) *Manager {
+	// Only read-only Managers get this far without a key
+	if secretKey == "" {
+		return NewManagerWithExchange(ctx, managerConfig, newInfoExchange())
 	}
 	hClient := hl.NewHyperliquid(&hl.HyperliquidClientConfig{
-		AccountAddress: botConfig.PasteAddress,
-		PrivateKey:     botConfig.SecretKey,
+		AccountAddress: address,
+		PrivateKey:     secretKey,
 		IsMainnet:      true,
 	})
-	hClient.CancelAllOrders()
-	for coin := range botConfig.CoinRiskMap {
-		hClient.ClosePosition(coin)
-	}
-	metaMapData, metaErr := hClient.BuildMetaMap()
+	return NewManagerWithExchange(ctx, managerConfig, hClient)
+}
+
+// NewManagerWithExchange creates a new Manager whose paste side trades through exchange
+func NewManagerWithExchange(ctx context.Context, managerConfig *config.HyperformanceConfig, exchange Exchange) *Manager {
+	metaMapData, metaErr := exchange.BuildMetaMap()
 	if metaErr != nil {
 		panic(metaErr)
 	}
+	if err := managerConfig.ValidateCoins(metaMapData); err != nil {
+		panic(err)
+	}
 	var permittedAssets []string
 	for assetSymbol, virtualLeverage := range managerConfig.CoinRiskMap {
 		_, assetFound := metaMapData[assetSymbol]
@@ -116,23 +255,50 @@ func NewManager(ctx context.Context) *Manager {
 	sort.Slice(permittedAssets, func(i, j int) bool {
 		return permittedAssets[i] < permittedAssets[j]
 	})
+	leaders := buildLeaders(managerConfig)
 	m := &Manager{
-		Client:             hClient,
-		CopyAddress:        strings.ToLower(managerConfig.CopyAddress),
+		Client:             exchange,
+		Config:             managerConfig,
+		CopyAddress:        leaders[0].Address,
+		Leaders:            leaders,
 		PasteAddress:       strings.ToLower(managerConfig.PasteAddress),
-		AllowedSymbols:     permittedAssets,
+		PasteScale:         1,
+		Endpoint:           endpoint,
+		allowedSymbols:     permittedAssets,
+		streamSymbols:      permittedAssets,
 		MetaMap:            metaMapData,
 		logStore:           sync.Map{},
-		CoinRiskMap:        managerConfig.CoinRiskMap,
+		coinRiskMap:        managerConfig.CoinRiskMap,
 		CopyWd2Chan:        make(chan *models.WebData2Message, 256),
 		PasteWd2Chan:       make(chan *models.WebData2Message, 256),
+		LeaderWd2Chan:      make(chan *models.WebData2Message, 256),
+		frames:             make(chan []byte, 1024),
 		OrderUpdatesChan:   make(chan *models.OrderMessage, 256),
+		UserFillsChan:      make(chan *models.UserFillsMessage, 256),
+		pausedEngines:      make(map[string]bool),
+		L2BookSnapshotChan: make(chan *models.L2BookSnapshotMessage, 256),
+		Books:              NewBookStore(),
 		UsedPasteWd2:       make(map[int64]time.Time),
 		UsedCopyAloCreates: make(map[int64]time.Time),
+		IocInFlight:        NewInFlightLedger(time.Duration(managerConfig.InFlightTtlMs) * time.Millisecond),
+		stateRetention:     defaultStateRetention,
 		i:                  0,
 	}
+	if managerConfig.WsEndpoint != "" {
+		m.Endpoint = managerConfig.WsEndpoint
+	}
+	if managerConfig.StateRetentionHours > 0 {
+		m.stateRetention = time.Duration(managerConfig.StateRetentionHours) * time.Hour
+	}
+	m.RiskGate = NewRiskGate(m, managerConfig.Risk)
+	m.Breaker = NewBreaker(m, managerConfig.Breaker)
+	if managerConfig.SyncLeverage {
+		m.LeverageSync = NewLeverageSync(m, managerConfig.MaxLeverage)
+	}
 	m.AloEngine = NewAloEngine(ctx, m, !managerConfig.DisableAloEngine)
 	m.IocEngine = NewIocEngine(ctx, m, !managerConfig.DisableIocEngine)
+	m.FillEngine = NewFillEngine(ctx, m, managerConfig.EnableFillEngine)
+	m.TriggerEngine = NewTriggerEngine(ctx, m, managerConfig.EnableTriggerEngine)
 	//m.ArchEngine = NewArchEngine(ctx, m)
 
 	m.AddLogFunc = m.defaultAddLog
@@ -164,7 +330,13 @@ func (m *Manager) GetLogs(address string, requestedCount int) []string {
 	return stored.(*models.RingBuffer).LastN(requestedCount)
 }
 func (m *Manager) handleL2BookSnapshot(l2BookSnapshot *models.L2BookSnapshotMessage) {
-	m.L2BookSnapshotChan <- l2BookSnapshot
+	m.Books.Update(l2BookSnapshot)
+	// Only the latest book matters, so a slow ALO engine misses snapshots rather than
+	// stalling the websocket.
+	select {
+	case m.L2BookSnapshotChan <- l2BookSnapshot:
+	default:
+	}
 }
 
 // func (m *Manager) postBroadcastWD2(address string, wd2 *models.WebData2Message) {
@@ -185,13 +357,18 @@ func (manager *Manager) handleOrderUpdates(rawData []byte) {
 	}
 	// By convention,
 	// manager.CopyOrderChan <- &orderUpdatesMessage
-	manager.OrderUpdatesChan <- orderUpdatesMessage
+	if len(manager.Children) == 0 {
+		manager.OrderUpdatesChan <- orderUpdatesMessage
+		return
+	}
+	manager.routeOrderUpdates(orderUpdatesMessage)
 
 }
 func (manager *Manager) handleActiveAssetData(userAssetData models.UserAssetData) {
 	address := userAssetData.User
 	key := address + ":" + userAssetData.Coin
 	manager.AssetDetailsStore.Store(key, models.AssetDetails{
+		LeverageType:     userAssetData.Leverage.Type,
 		LeverageValue:    userAssetData.Leverage.Value,
 		MaxTradeAmounts:  userAssetData.MaxTradeSzs,
 		AvailableToTrade: userAssetData.AvailableToTrade,
@@ -200,46 +377,16 @@ func (manager *Manager) handleActiveAssetData(userAssetData models.UserAssetData
 	n := v.(int) + 1
 	manager.AssetSubscriptionCount.Store(address, n)
 	if strings.EqualFold(address, manager.CopyAddress) {
-		if !manager.CopyAssetDataReady && n >= len(manager.AllowedSymbols) {
+		if !manager.CopyAssetDataReady && n >= len(manager.streamSymbols) {
 			manager.CopyAssetDataReady = true
 		}
-	} else {
-		if !manager.PasteAssetDataReady && n >= len(manager.AllowedSymbols) {
+	} else if strings.EqualFold(address, manager.PasteAddress) {
+		if !manager.PasteAssetDataReady && n >= len(manager.streamSymbols) {
 			manager.PasteAssetDataReady = true
 		}
 	}
 }
 
-// func (m *Manager) sendIocOrders(orders []hl.Order) {
-// 	requests := m.ConvertOrdersToOrderRequests(orders)
-// 	resp, err := m.Client.BulkOrders(requests, hl.GroupingNa)
-
-// 	if err != nil || resp.Status != "ok" {
-// 		for _, o := range orders {
-// 			revert := sideSign(o.Side) * o.Sz
-// 			m.iocSzInFlight[o.Coin] -= revert
-// 			if math.Abs(m.iocSzInFlight[o.Coin]) < 1e-8 {
-// 				m.iocSzInFlight[o.Coin] = 0
-// 			}
-// 		}
-// 		return
-// 	}
-
-// 	for i, st := range resp.Response.Data.Statuses {
-// 		o := orders[i]
-// 		if st.Error != "" || st.Resting.OrderID == 0 {
-// 			revert := sideSign(o.Side) * o.Sz
-// 			m.iocSzInFlight[o.Coin] -= revert
-// 		} else {
-// 			filled := sideSign(o.Side) * st.Filled.TotalSz
-// 			m.iocSzInFlight[o.Coin] -= filled
-// 		}
-// 		if math.Abs(m.iocSzInFlight[o.Coin]) < 1e-8 {
-// 			m.iocSzInFlight[o.Coin] = 0
-// 		}
-// 	}
-// }
-
 // func (manager *Manager) handleWebData2(webData models.WebData2Message, address string) {
 // 	manager.WebData2Chan <- webData
 
@@ -247,7 +394,7 @@ func (manager *Manager) handleActiveAssetData(userAssetData models.UserAssetData
 // 	nowTime := time.Now()
 
 // 	if strings.EqualFold(address, manager.CopyAddress) {
-// 		for _, symbol := range manager.AllowedSymbols {
+// 		for _, symbol := range manager.AllowedSymbols() {
 // 			assetInfo, foundAsset := manager.MetaMap[symbol]
 // 			if foundAsset {
 // 				manager.AssetCtxStore.Store(symbol, webData.Data.AssetCtxs[assetInfo.AssetID])
@@ -290,14 +437,48 @@ func (manager *Manager) handleActiveAssetData(userAssetData models.UserAssetData
 //		}
 //	}
 func (manager *Manager) IsReady() bool {
-	copySideReady := manager.CopyWebSocketReady && manager.CopyAssetDataReady
+	copySideReady := manager.CopyWebSocketReady && manager.CopyAssetDataReady && manager.leadersReady()
 	pasteSideReady := manager.PasteWebSocketReady && manager.PasteAssetDataReady
 
 	return copySideReady && pasteSideReady
 }
 
+// Close releases resources held for the session, such as an open recording.
+func (manager *Manager) Close() error {
+	var err error
+	if manager.Recorder != nil {
+		err = manager.Recorder.Close()
+	}
+	if manager.Store != nil {
+		err = errors.Join(err, manager.Store.Close())
+	}
+	for _, child := range manager.Children {
+		err = errors.Join(err, child.Close())
+	}
+	return err
+}
+
+// IsDryRun reports whether the paste account is simulated.
+func (manager *Manager) IsDryRun() bool {
+	return manager.Paper != nil
+}
+
+// AllowedSymbols returns the enabled coins, sorted.
+func (manager *Manager) AllowedSymbols() []string {
+	manager.controlMu.Lock()
+	defer manager.controlMu.Unlock()
+	return manager.allowedSymbols
+}
+
+// CoinRiskMap returns the configured coins and their weights.
+func (manager *Manager) CoinRiskMap() map[string]float64 {
+	manager.controlMu.Lock()
+	defer manager.controlMu.Unlock()
+	return manager.coinRiskMap
+}
+
 func (manager *Manager) IsEnabledCoin(symbol string) bool {
-	for _, allowedSymbol := range manager.AllowedSymbols {
+	for _, allowedSymbol := range manager.AllowedSymbols() {
 		if strings.EqualFold(symbol, allowedSymbol) {
 			return true
 		}
@@ -314,16 +495,6 @@ func (manager *Manager) GetMidPrice(symbol string) float64 {
 	return assetContextValue.(models.AssetCtx).MidPx
 }
 
-func (manager *Manager) getMarketPrice(order hl.Order) float64 {
-	midPrice := manager.GetMidPrice(order.Coin)
-	if order.Side == "B" {
-		midPrice *= 1.08
-	} else {
-		midPrice *= 0.92
-	}
-	return manager.SnapPrice(order.Coin, midPrice)
-}
-
 func (manager *Manager) SnapPrice(coinSymbol string, originalPrice float64) float64 {
 	if originalPrice <= 0 {
 		logger.LogWarnf("[snapPrice] <=0 => %s px=%.2f", coinSymbol, originalPrice)
@@ -352,54 +523,77 @@ func (manager *Manager) SnapPrice(coinSymbol string, originalPrice float64) floa
 	return snappedPrice
 }
 
-// scaleSize returns the signed size.
+// scaleSize returns the unsigned paste size of a resting copy order of the primary leader.
 func (manager *Manager) scaleSize(order hl.Order) float64 {
-	scaleFactor := manager.deriveScaleFactor(order.Coin, order.Side)
-	scaledSize := RoundToPrecision(order.Sz*scaleFactor, manager.Decimals(order.Coin))
-	//logger.LogInfof("[scaleSize] => coin=%s side=%s value=%.6f scale=%.6fx", order.Coin, order.Side, order.Sz, scaleFactor)
+	return manager.scaleFill(order, time.Time{})
+}
+
+// scaleFill returns the unsigned paste size of a copy order of the primary leader that
+// filled at filledAt.
+func (manager *Manager) scaleFill(order hl.Order, filledAt time.Time) float64 {
+	leaderDelta := sideSign(order.Side) * math.Abs(order.Sz)
+	scaledSize := RoundToPrecision(math.Abs(manager.primarySize(order.Coin, leaderDelta, filledAt)), manager.Decimals(order.Coin))
+	//logger.LogInfof("[scaleSize] => coin=%s side=%s value=%.6f sz=%.6f", order.Coin, order.Side, order.Sz, scaledSize)
 	return scaledSize
 }
 func (manager *Manager) scaleSizeWithMultiplier(orderObject hl.Order, multiplier float64) float64 {
-	scaleFactor := manager.deriveScaleFactor(orderObject.Coin, orderObject.Side)
-	scaledValue := RoundToPrecision(orderObject.Sz*scaleFactor*multiplier, manager.Decimals(orderObject.Coin))
-	logger.LogInfof("[scaleSizeWithMultiplier] => c=%s side=%s o=%.6f r=%.6f sf=%.6f sz=%.6f",
+	leaderDelta := sideSign(orderObject.Side) * math.Abs(orderObject.Sz*multiplier)
+	scaledValue := RoundToPrecision(math.Abs(manager.primarySize(orderObject.Coin, leaderDelta, time.Time{})), manager.Decimals(orderObject.Coin))
+	logger.LogInfof("[scaleSizeWithMultiplier] => c=%s side=%s o=%.6f r=%.6f mode=%s sz=%.6f",
 		orderObject.Coin,
 		orderObject.Side,
 		orderObject.Sz,
 		multiplier,
-		scaleFactor,
+		manager.SizingFor(orderObject.Coin).Mode,
 		scaledValue,
 	)
 	return scaledValue
 }
 
-func (m *Manager) NewPasteIocCloid(uniqueId int64) string {
-	pasteCloidVal, _ := strconv.Atoi(fmt.Sprintf("1337%v", uniqueId))
-	nextCloidValue := big.NewInt(int64(pasteCloidVal))
-	return hl.IntToHex(nextCloidValue)
+// NewPasteCloid mints the cloid of a paste order placed by engine for source: the copy
+// oid, tid or clearinghouse time it follows. leader is the index in Leaders of the
+// account whose order it mirrors.
+func (m *Manager) NewPasteCloid(engine cloid.Engine, leader uint8, source uint64) string {
+	return cloid.ID{BotID: m.Config.BotID, Engine: engine, Leader: leader, Source: source}.String()
 }
-func (manager *Manager) deriveScaleFactor(symbol, side string) float64 {
-	if manager.CopyWd2 == nil || manager.PasteWd2 == nil {
-		logger.LogErrorf("[deriveScaleFactor] copy copyWd2 or pasteWd2 was nil")
-		logger.LogErrorf("[deriveScaleFactor] copy copyWd2: %#+v", manager.CopyWd2)
-		logger.LogErrorf("[deriveScaleFactor] paste pasteWd2: %#+v", manager.PasteWd2)
-		return 0
+
+// NewReconcileCloid mints the cloid of a reconcile order for the clearinghouse time it
+// follows. Orders of one batch, and of passes over the same snapshot, share that time,
+// so each takes the next sequence of this Manager.
+func (m *Manager) NewReconcileCloid(chTime uint64) string {
+	seq := m.reconcileSeq.Add(1) & cloid.MaxSeq
+	return cloid.ID{BotID: m.Config.BotID, Engine: cloid.EngineReconcile, Seq: seq, Source: chTime}.String()
+}
+
+// OwnsCloid reports whether this bot minted cloid, with one of engines if any are given.
+func (m *Manager) OwnsCloid(c string, engines ...cloid.Engine) bool {
+	return cloid.Owned(c, m.Config.BotID, engines...)
+}
+
+// isPasteOrderUpdate tells paste orderUpdates apart from copy ones, as both arrive on
+// the same channel without a user.
+func (manager *Manager) isPasteOrderUpdate(update models.OrderUpdate) bool {
+	if manager.OwnsCloid(update.Order.Cloid) || manager.IocInFlight.Has(update.Order.Coin, update.Order.Cloid) {
+		return true
+	}
+	if manager.PasteWd2 == nil {
+		return false
 	}
-	copyAccountValue := manager.CopyWd2.AccountValue()
-	pasteAccountValue := manager.PasteWd2.AccountValue()
-	// copyLeverage := copyAsset.LeverageValue
-	// pasteLeverage := pasteAsset.LeverageValue
+	_, found := manager.PasteWd2.OrdersByOid()[update.Order.Oid]
+	return found
+}
 
-	if copyAccountValue == 0 || pasteAccountValue == 0 {
-		logger.LogErrorf("[deriveScaleFactor] copy paste copyAvailable= $%v == 0 || pasteAvailable= $%v == 0", copyAccountValue, pasteAccountValue)
-		return 0
+// syncLeverage brings the paste leverage on every coin of requests in line with the
+// copy side, when leverage sync is enabled.
+func (manager *Manager) syncLeverage(requests []hl.OrderRequest) {
+	if manager.LeverageSync == nil {
+		return
+	}
+	coins := make([]string, 0, len(requests))
+	for _, request := range requests {
+		coins = append(coins, request.Coin)
 	}
-	virtualLeverage := manager.CoinRiskMap[symbol]
-	pasteScaleFactor := pasteAccountValue
-	copyScaleFactor := copyAccountValue
-	scaleFactor := pasteScaleFactor / copyScaleFactor
-	scaleFactor *= virtualLeverage
-	return scaleFactor
+	manager.LeverageSync.EnsureAll(coins)
 }
 
 func (manager *Manager) Decimals(symbol string) int {
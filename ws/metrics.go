@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/metrics"
+	"github.com/itay747/hyperformance/models"
+)
+
+var (
+	wsReconnects = metrics.NewCounterVec("hyperformance_ws_reconnects_total",
+		"Websocket connections made after the first.")
+	wsFrames = metrics.NewCounterVec("hyperformance_ws_frames_total",
+		"Inbound websocket frames by channel.", "channel")
+	webData2Latency = metrics.NewHistogramVec("hyperformance_webdata2_latency_seconds",
+		"Delay between a webData2 serverTime and its receipt.", metrics.LatencyBuckets, "account", "side")
+	ordersSent = metrics.NewCounterVec("hyperformance_orders_sent_total",
+		"Paste orders and modifies sent to the exchange.", "engine", "coin")
+	ordersRejected = metrics.NewCounterVec("hyperformance_orders_rejected_total",
+		"Paste orders and modifies the exchange rejected or that failed to send.", "engine", "coin")
+	riskRejections = metrics.NewCounterVec("hyperformance_risk_rejections_total",
+		"Paste orders the risk gate dropped before sending.", "reason", "coin")
+	bulkOrderSeconds = metrics.NewHistogramVec("hyperformance_bulk_order_seconds",
+		"Round trip of bulk order and modify calls.", metrics.LatencyBuckets, "engine")
+	driftNotionalUsd = metrics.NewGaugeVec("hyperformance_drift_notional_usd",
+		"Signed USD notional the paste position lacks to reach the copy target.", "account", "coin")
+	accountValueUsd = metrics.NewGaugeVec("hyperformance_account_value_usd",
+		"Account value from the latest webData2.", "account", "side")
+	queueDepth = metrics.NewGaugeVec("hyperformance_queue_depth",
+		"Messages waiting in a Manager channel.", "account", "queue")
+)
+
+// RegisterMetrics sets the computed gauges of this Manager and its children on every
+// scrape.
+func (manager *Manager) RegisterMetrics() {
+	metrics.OnScrape(manager.collectMetrics)
+	for _, child := range manager.Children {
+		metrics.OnScrape(child.collectMetrics)
+	}
+}
+
+func (manager *Manager) collectMetrics() {
+	account := manager.PasteAddress
+	queueDepth.Set(float64(len(manager.CopyWd2Chan)), account, "CopyWd2Chan")
+	queueDepth.Set(float64(len(manager.PasteWd2Chan)), account, "PasteWd2Chan")
+	queueDepth.Set(float64(len(manager.LeaderWd2Chan)), account, "LeaderWd2Chan")
+	queueDepth.Set(float64(len(manager.OrderUpdatesChan)), account, "OrderUpdatesChan")
+	copyWd2, pasteWd2 := manager.CopyWd2, manager.PasteWd2
+	if copyWd2 != nil {
+		accountValueUsd.Set(copyWd2.AccountValue(), account, "copy")
+	}
+	if pasteWd2 != nil {
+		accountValueUsd.Set(pasteWd2.AccountValue(), account, "paste")
+	}
+	if copyWd2 == nil || pasteWd2 == nil {
+		return
+	}
+	for coin, drift := range manager.DriftNotional() {
+		driftNotionalUsd.Set(drift, account, coin)
+	}
+}
+
+// observeWebData2 records how long wd2 took to arrive. Replayed frames are skipped, their
+// serverTime is from the recording.
+func (manager *Manager) observeWebData2(wd2 *models.WebData2Message, side string) {
+	if manager.ReplayPath != "" {
+		return
+	}
+	webData2Latency.Observe(time.Since(wd2.ServerTime()).Seconds(), manager.PasteAddress, side)
+}
+
+// bulkOrders places requests for engine, timing the call and counting what was sent and
+// rejected.
+func (manager *Manager) bulkOrders(engine string, requests []hl.OrderRequest, grouping hl.Grouping) (*hl.OrderResponse, error) {
+	start := time.Now()
+	resp, err := manager.Client.BulkOrders(requests, grouping)
+	observeBulk(engine, requests, resp, err, time.Since(start))
+	return resp, err
+}
+
+func (manager *Manager) bulkModifyOrdersByCloid(engine string, requests []hl.OrderRequest) (*hl.OrderResponse, error) {
+	start := time.Now()
+	resp, err := manager.Client.BulkModifyOrdersByCloid(requests)
+	observeBulk(engine, requests, resp, err, time.Since(start))
+	return resp, err
+}
+
+func observeBulk(engine string, requests []hl.OrderRequest, resp *hl.OrderResponse, err error, elapsed time.Duration) {
+	bulkOrderSeconds.Observe(elapsed.Seconds(), engine)
+	failed := err != nil || resp == nil || resp.Status != "ok"
+	for i, request := range requests {
+		ordersSent.Inc(engine, request.Coin)
+		if failed || i < len(resp.Response.Data.Statuses) && resp.Response.Data.Statuses[i].Error != "" {
+			ordersRejected.Inc(engine, request.Coin)
+		}
+	}
+}
@@ -0,0 +1,641 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+	"github.com/itay747/hyperformance/utils"
+)
+
+var (
+	paperTickEvery       = 500 * time.Millisecond
+	defaultPaperBalance  = 10000.0
+	defaultPaperTakerBps = 4.5
+	defaultPaperMakerBps = 1.5
+	defaultPaperLeverage = 10
+	paperAddress         = "0x0000000000000000000000000000000000000000"
+)
+
+// PaperExchange is the simulated paste account behind --dry-run. Marketable orders walk
+// the latest l2Book up to their limit, or fill at the AssetCtx mid without one, ALO
+// orders rest until the mid trades through them, trigger orders fire once the mid
+// reaches their trigger price, and the account is published back as webData2 and
+// activeAssetData frames through the same path as websocket frames.
+type PaperExchange struct {
+	mu      sync.Mutex
+	manager *Manager
+	info    *hl.InfoAPI
+
+	balance   float64
+	feesPaid  float64
+	takerBps  float64
+	makerBps  float64
+	positions map[string]*paperPosition
+	resting   map[string]hl.Order
+	leverage  map[string]int
+	isolated  map[string]bool
+	oid       int64
+	lastTime  int64
+	// clock, once set by Step, stands in for the wall clock so a replay stamps the
+	// paper account with the recording's time
+	clock int64
+}
+
+type paperPosition struct {
+	Szi     float64
+	EntryPx float64
+}
+
+func NewPaperExchange(cfg *config.HyperformanceConfig) *PaperExchange {
+	paper := &PaperExchange{
+		info:      hl.NewInfoAPI(true),
+		balance:   defaultPaperBalance,
+		takerBps:  defaultPaperTakerBps,
+		makerBps:  defaultPaperMakerBps,
+		positions: make(map[string]*paperPosition),
+		resting:   make(map[string]hl.Order),
+		leverage:  make(map[string]int),
+		isolated:  make(map[string]bool),
+	}
+	if cfg.PaperBalance > 0 {
+		paper.balance = cfg.PaperBalance
+	}
+	if cfg.PaperTakerFeeBps > 0 {
+		paper.takerBps = cfg.PaperTakerFeeBps
+	}
+	if cfg.PaperMakerFeeBps > 0 {
+		paper.makerBps = cfg.PaperMakerFeeBps
+	}
+	return paper
+}
+
+// Run fills resting orders and publishes the paper account through the manager's
+// inbound frames until ctx is done.
+func (p *PaperExchange) Run(ctx context.Context) {
+	ticker := time.NewTicker(paperTickEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.fillResting()
+			for _, frame := range p.frames() {
+				select {
+				case <-ctx.Done():
+					return
+				case p.manager.frames <- frame:
+				}
+			}
+		}
+	}
+}
+
+// Step sets the paper clock to now, in unix milliseconds, fills resting orders and
+// returns the frames publishing the account. A replay calls it in place of Run.
+func (p *PaperExchange) Step(now int64) [][]byte {
+	p.mu.Lock()
+	p.clock = now
+	p.mu.Unlock()
+	p.fillResting()
+	return p.frames()
+}
+
+// Fees returns the total fees paid by the paper account.
+func (p *PaperExchange) Fees() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.feesPaid
+}
+
+func (p *PaperExchange) BulkOrders(requests []hl.OrderRequest, grouping hl.Grouping) (*hl.OrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]hl.StatusResponse, 0, len(requests))
+	for _, req := range requests {
+		statuses = append(statuses, p.placeLocked(req))
+	}
+	return paperOrderResponse(statuses), nil
+}
+
+func (p *PaperExchange) BulkModifyOrdersByCloid(requests []hl.OrderRequest) (*hl.OrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]hl.StatusResponse, 0, len(requests))
+	for _, req := range requests {
+		if _, ok := p.resting[req.Cloid]; !ok {
+			statuses = append(statuses, hl.StatusResponse{Error: "Cannot modify canceled or filled order"})
+			continue
+		}
+		// a rejected modify, such as an ALO that would cross, leaves the order resting
+		resting := p.resting[req.Cloid]
+		delete(p.resting, req.Cloid)
+		status := p.placeLocked(req)
+		if status.Error != "" {
+			p.resting[req.Cloid] = resting
+		}
+		statuses = append(statuses, status)
+	}
+	return paperOrderResponse(statuses), nil
+}
+
+func (p *PaperExchange) BulkCancelOrdersByCloid(cancels []hl.CancelCloidWire) (*hl.OrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]hl.StatusResponse, 0, len(cancels))
+	for _, cancel := range cancels {
+		if _, ok := p.resting[cancel.Cloid]; !ok {
+			statuses = append(statuses, hl.StatusResponse{Error: "Order was never placed, already canceled, or filled."})
+			continue
+		}
+		delete(p.resting, cancel.Cloid)
+		statuses = append(statuses, hl.StatusResponse{Status: "success"})
+	}
+	return paperOrderResponse(statuses), nil
+}
+
+func (p *PaperExchange) CancelOrderByOID(coin string, orderID int) (*hl.OrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for cloid, order := range p.resting {
+		if order.Coin == coin && order.Oid == int64(orderID) {
+			delete(p.resting, cloid)
+			return paperOrderResponse([]hl.StatusResponse{{Status: "success"}}), nil
+		}
+	}
+	return paperOrderResponse([]hl.StatusResponse{{Error: "Order was never placed, already canceled, or filled."}}), nil
+}
+
+func (p *PaperExchange) CancelAllOrders() (*hl.OrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]hl.StatusResponse, 0, len(p.resting))
+	for cloid := range p.resting {
+		delete(p.resting, cloid)
+		statuses = append(statuses, hl.StatusResponse{Status: "success"})
+	}
+	return paperOrderResponse(statuses), nil
+}
+
+func (p *PaperExchange) ClosePosition(coin string) (*hl.OrderResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pos, ok := p.positions[coin]
+	if !ok || pos.Szi == 0 {
+		return paperOrderResponse(nil), nil
+	}
+	req := hl.OrderRequest{
+		Coin:       coin,
+		IsBuy:      pos.Szi < 0,
+		Sz:         math.Abs(pos.Szi),
+		OrderType:  hl.OrderType{Limit: &hl.LimitOrderType{Tif: hl.TifIoc}},
+		ReduceOnly: true,
+	}
+	if req.IsBuy {
+		req.LimitPx = math.MaxFloat64
+	}
+	return paperOrderResponse([]hl.StatusResponse{p.placeLocked(req)}), nil
+}
+
+func (p *PaperExchange) UpdateLeverage(coin string, isCross bool, leverage int) (*hl.DefaultExchangeResponse, error) {
+	if leverage <= 0 {
+		return nil, fmt.Errorf("invalid leverage %d", leverage)
+	}
+	p.mu.Lock()
+	p.leverage[coin] = leverage
+	p.isolated[coin] = !isCross
+	p.mu.Unlock()
+	return &hl.DefaultExchangeResponse{Status: "ok"}, nil
+}
+
+func (p *PaperExchange) BuildMetaMap() (map[string]hl.AssetInfo, error) {
+	return p.info.BuildMetaMap()
+}
+
+func paperOrderResponse(statuses []hl.StatusResponse) *hl.OrderResponse {
+	resp := &hl.OrderResponse{Status: "ok"}
+	resp.Response.Type = "order"
+	resp.Response.Data.Statuses = statuses
+	return resp
+}
+
+func (p *PaperExchange) placeLocked(req hl.OrderRequest) hl.StatusResponse {
+	mid := p.midLocked(req.Coin)
+	if mid <= 0 {
+		return hl.StatusResponse{Error: fmt.Sprintf("No mid price for %s", req.Coin)}
+	}
+	if req.OrderType.Trigger != nil {
+		return p.restTriggerLocked(req)
+	}
+	sz := req.Sz
+	if req.ReduceOnly {
+		sz = p.reducibleLocked(req.Coin, req.IsBuy, sz)
+		if sz <= 1e-9 {
+			return hl.StatusResponse{Error: "Reduce only order would increase position."}
+		}
+	}
+	tif := hl.TifGtc
+	if req.OrderType.Limit != nil {
+		tif = req.OrderType.Limit.Tif
+	}
+	filled, avgPx := p.takeLocked(req.Coin, req.IsBuy, sz, req.LimitPx, mid)
+	if tif == hl.TifAlo && filled > 0 {
+		return hl.StatusResponse{Error: "Post only order would have immediately matched."}
+	}
+	if !p.hasMarginLocked(req.Coin, req.IsBuy, sz, mid) {
+		return hl.StatusResponse{Error: "Insufficient margin to place order."}
+	}
+	if filled == 0 && (tif == hl.TifIoc || tif == hl.TifFrontendMarket) {
+		return hl.StatusResponse{Error: "Order could not immediately match against any resting orders."}
+	}
+	p.oid++
+	if filled > 0 {
+		p.fillLocked(req.Coin, req.IsBuy, filled, avgPx, p.takerBps)
+	}
+	// an IOC's unfilled rest is canceled, a GTC's rests on the book
+	if sz-filled <= 1e-9 || tif == hl.TifIoc || tif == hl.TifFrontendMarket {
+		return hl.StatusResponse{Filled: hl.FilledStatus{OrderID: int(p.oid), AvgPx: avgPx, TotalSz: filled, Cloid: req.Cloid}}
+	}
+	side := "B"
+	if !req.IsBuy {
+		side = "A"
+	}
+	p.resting[req.Cloid] = hl.Order{
+		Coin:       req.Coin,
+		Side:       side,
+		LimitPx:    req.LimitPx,
+		Sz:         sz - filled,
+		OrigSz:     sz,
+		Oid:        p.oid,
+		Cloid:      req.Cloid,
+		Tif:        tif,
+		OrderType:  "Limit",
+		ReduceOnly: req.ReduceOnly,
+		Timestamp:  p.nowLocked(),
+	}
+	return hl.StatusResponse{Resting: hl.RestingStatus{OrderID: int(p.oid), Cloid: req.Cloid}}
+}
+
+// takeLocked walks the coin's latest l2Book for an order of sz up to limitPx, returning
+// the size it would take and its average price. Without a book the order takes all of
+// sz at mid if its limit reaches it.
+func (p *PaperExchange) takeLocked(coin string, isBuy bool, sz, limitPx, mid float64) (filled, avgPx float64) {
+	var levels []models.BookLevel
+	if book, ok := p.manager.Books.Get(coin); ok {
+		levels = book.Bids()
+		if isBuy {
+			levels = book.Asks()
+		}
+	}
+	if len(levels) == 0 {
+		if isBuy && limitPx >= mid || !isBuy && limitPx <= mid {
+			return sz, mid
+		}
+		return 0, 0
+	}
+	var notional float64
+	for _, level := range levels {
+		if isBuy && level.Price > limitPx || !isBuy && level.Price < limitPx {
+			break
+		}
+		take := math.Min(level.Size, sz-filled)
+		filled += take
+		notional += take * level.Price
+		if filled >= sz {
+			break
+		}
+	}
+	if filled == 0 {
+		return 0, 0
+	}
+	return filled, notional / filled
+}
+
+// fillLocked applies a fill to the position, realizing PnL on the reduced part and
+// charging fees against the balance.
+func (p *PaperExchange) fillLocked(coin string, isBuy bool, sz, px, feeBps float64) {
+	pos, ok := p.positions[coin]
+	if !ok {
+		pos = &paperPosition{}
+		p.positions[coin] = pos
+	}
+	signed := sz
+	if !isBuy {
+		signed = -sz
+	}
+	if pos.Szi == 0 || (pos.Szi > 0) == isBuy {
+		pos.EntryPx = (pos.EntryPx*math.Abs(pos.Szi) + px*sz) / (math.Abs(pos.Szi) + sz)
+		pos.Szi += signed
+	} else {
+		closing := math.Min(sz, math.Abs(pos.Szi))
+		p.balance += closing * (px - pos.EntryPx) * math.Copysign(1, pos.Szi)
+		pos.Szi += signed
+		if math.Abs(pos.Szi) < 1e-9 {
+			pos.Szi = 0
+			pos.EntryPx = 0
+		} else if sz > closing {
+			pos.EntryPx = px
+		}
+	}
+	fee := sz * px * feeBps / 1e4
+	p.balance -= fee
+	p.feesPaid += fee
+	side := "B"
+	fields := utils.Fields{Coin: coin, Side: "BUY", Size: sz, Px: px}
+	if !isBuy {
+		side = "A"
+		fields.Side = "SELL"
+	}
+	logger.LogFields("info", fmt.Sprintf("[Paper] paste fill %s %s %v @ %v fee=$%.4f", side, coin, sz, px, fee), fields)
+}
+
+// restTriggerLocked rests a trigger order until fillResting sees the mid reach its
+// trigger price. A trigger without a size is a position TP/SL.
+func (p *PaperExchange) restTriggerLocked(req hl.OrderRequest) hl.StatusResponse {
+	trigger := req.OrderType.Trigger
+	triggerPx, err := strconv.ParseFloat(trigger.TriggerPx, 64)
+	if err != nil || triggerPx <= 0 {
+		return hl.StatusResponse{Error: fmt.Sprintf("Invalid trigger price %q", trigger.TriggerPx)}
+	}
+	orderType := "Stop"
+	if trigger.TpSl == hl.TriggerTp {
+		orderType = "Take Profit"
+	}
+	if trigger.IsMarket {
+		orderType += " Market"
+	} else {
+		orderType += " Limit"
+	}
+	side := "B"
+	if !req.IsBuy {
+		side = "A"
+	}
+	p.oid++
+	p.resting[req.Cloid] = hl.Order{
+		Coin:           req.Coin,
+		Side:           side,
+		LimitPx:        req.LimitPx,
+		Sz:             req.Sz,
+		OrigSz:         req.Sz,
+		Oid:            p.oid,
+		Cloid:          req.Cloid,
+		OrderType:      orderType,
+		ReduceOnly:     req.ReduceOnly,
+		IsTrigger:      true,
+		TriggerPx:      triggerPx,
+		IsPositionTpsl: req.Sz == 0,
+		Timestamp:      p.nowLocked(),
+	}
+	return hl.StatusResponse{Resting: hl.RestingStatus{OrderID: int(p.oid), Cloid: req.Cloid}}
+}
+
+// fireTriggerLocked fires a trigger order once the mid reaches its trigger price: market
+// triggers fill at the mid, limit triggers rest as a plain limit order.
+func (p *PaperExchange) fireTriggerLocked(cloid string, order hl.Order, mid float64) {
+	isBuy := order.Side == "B"
+	// a take profit sell and a stop buy trigger when the price rises to them
+	triggersAbove := strings.HasPrefix(order.OrderType, "Take Profit") != isBuy
+	if triggersAbove && mid < order.TriggerPx || !triggersAbove && mid > order.TriggerPx {
+		return
+	}
+	delete(p.resting, cloid)
+	sz := order.Sz
+	if order.IsPositionTpsl && sz == 0 {
+		sz = math.MaxFloat64
+	}
+	if order.ReduceOnly {
+		sz = p.reducibleLocked(order.Coin, isBuy, sz)
+	}
+	if sz <= 1e-9 {
+		return
+	}
+	if strings.HasSuffix(order.OrderType, "Market") {
+		p.fillLocked(order.Coin, isBuy, sz, mid, p.takerBps)
+		return
+	}
+	order.IsTrigger = false
+	order.OrderType = "Limit"
+	order.Tif = hl.TifGtc
+	order.Sz = sz
+	p.resting[cloid] = order
+}
+
+func (p *PaperExchange) fillResting() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for cloid, order := range p.resting {
+		mid := p.midLocked(order.Coin)
+		if mid <= 0 {
+			continue
+		}
+		if order.IsTrigger {
+			p.fireTriggerLocked(cloid, order, mid)
+			continue
+		}
+		isBuy := order.Side == "B"
+		if (isBuy && mid > order.LimitPx) || (!isBuy && mid < order.LimitPx) {
+			continue
+		}
+		sz := order.Sz
+		if order.ReduceOnly {
+			sz = p.reducibleLocked(order.Coin, isBuy, sz)
+		}
+		delete(p.resting, cloid)
+		if sz > 1e-9 {
+			p.fillLocked(order.Coin, isBuy, sz, order.LimitPx, p.makerBps)
+		}
+	}
+}
+
+func (p *PaperExchange) reducibleLocked(coin string, isBuy bool, sz float64) float64 {
+	pos, ok := p.positions[coin]
+	if !ok || pos.Szi == 0 || (pos.Szi > 0) == isBuy {
+		return 0
+	}
+	return math.Min(sz, math.Abs(pos.Szi))
+}
+
+func (p *PaperExchange) hasMarginLocked(coin string, isBuy bool, sz, mid float64) bool {
+	if p.reducibleLocked(coin, isBuy, sz) >= sz {
+		return true
+	}
+	accountValue, marginUsed := p.accountLocked()
+	return accountValue-marginUsed >= sz*mid/float64(p.leverageLocked(coin))
+}
+
+func (p *PaperExchange) accountLocked() (accountValue, marginUsed float64) {
+	accountValue = p.balance
+	for coin, pos := range p.positions {
+		mid := p.midLocked(coin)
+		accountValue += pos.Szi * (mid - pos.EntryPx)
+		marginUsed += math.Abs(pos.Szi) * mid / float64(p.leverageLocked(coin))
+	}
+	return accountValue, marginUsed
+}
+
+func (p *PaperExchange) leverageLocked(coin string) int {
+	if lev, ok := p.leverage[coin]; ok {
+		return lev
+	}
+	return defaultPaperLeverage
+}
+
+func (p *PaperExchange) leverageTypeLocked(coin string) string {
+	if p.isolated[coin] {
+		return "isolated"
+	}
+	return "cross"
+}
+
+func (p *PaperExchange) nowLocked() int64 {
+	if p.clock > 0 {
+		return p.clock
+	}
+	return time.Now().UnixMilli()
+}
+
+func (p *PaperExchange) midLocked(coin string) float64 {
+	value, ok := p.manager.AssetCtxStore.Load(coin)
+	if !ok {
+		return 0
+	}
+	return value.(models.AssetCtx).MidPx
+}
+
+// frames builds a synthetic paste webData2 frame and an activeAssetData frame for every
+// enabled coin, in the websocket's wire format. It returns nil until a copy snapshot has
+// supplied the asset contexts.
+func (p *PaperExchange) frames() [][]byte {
+	assetCtxs, ok := p.assetCtxs()
+	if !ok {
+		return nil
+	}
+	p.mu.Lock()
+	wd2 := p.snapshotLocked(assetCtxs)
+	var assetData []models.WireActiveAssetData
+	accountValue, marginUsed := p.accountLocked()
+	free := math.Max(accountValue-marginUsed, 0)
+	for _, coin := range p.manager.AllowedSymbols() {
+		mid := p.midLocked(coin)
+		lev := p.leverageLocked(coin)
+		data := models.WireActiveAssetData{User: p.manager.PasteAddress, Coin: coin}
+		data.Leverage.Type = p.leverageTypeLocked(coin)
+		data.Leverage.Value = float64(lev)
+		available := 0.0
+		if mid > 0 {
+			available = free * float64(lev) / mid
+		}
+		maxTradeSz := strconv.FormatFloat(available, 'f', -1, 64)
+		freeUsd := strconv.FormatFloat(free, 'f', -1, 64)
+		data.MaxTradeSzs = []string{maxTradeSz, maxTradeSz}
+		data.AvailableToTrade = []string{freeUsd, freeUsd}
+		assetData = append(assetData, data)
+	}
+	p.mu.Unlock()
+
+	raw, err := json.Marshal(wd2)
+	if err != nil {
+		logger.LogErrorf("[Paper] paste marshal webData2 => %v", err)
+		return nil
+	}
+	frames := [][]byte{raw}
+	for _, data := range assetData {
+		raw, err := json.Marshal(map[string]any{"channel": "activeAssetData", "data": data})
+		if err != nil {
+			logger.LogErrorf("[Paper] paste marshal activeAssetData => %v", err)
+			continue
+		}
+		frames = append(frames, raw)
+	}
+	return frames
+}
+
+// assetCtxs lays the stored asset contexts out by asset id, as a webData2 carries them.
+// ok is false before any has been stored.
+func (p *PaperExchange) assetCtxs() (assetCtxs []models.AssetCtx, ok bool) {
+	for _, info := range p.manager.MetaMap {
+		for len(assetCtxs) <= info.AssetID {
+			assetCtxs = append(assetCtxs, models.AssetCtx{})
+		}
+	}
+	for symbol, info := range p.manager.MetaMap {
+		if value, found := p.manager.AssetCtxStore.Load(symbol); found {
+			assetCtxs[info.AssetID] = value.(models.AssetCtx)
+			ok = true
+		}
+	}
+	return assetCtxs, ok
+}
+
+func (p *PaperExchange) snapshotLocked(assetCtxs []models.AssetCtx) *models.WebData2Message {
+	now := p.nowLocked()
+	if now <= p.lastTime {
+		now = p.lastTime + 1
+	}
+	p.lastTime = now
+
+	wd2 := &models.WebData2Message{Channel: "webData2"}
+	wd2.Data.User = p.manager.PasteAddress
+	wd2.Data.ServerTime = now
+	wd2.Data.AssetCtxs = assetCtxs
+	state := &wd2.Data.ClearinghouseState
+	state.Time = now
+
+	accountValue, marginUsed := p.accountLocked()
+	coins := make([]string, 0, len(p.positions))
+	for coin, pos := range p.positions {
+		if pos.Szi != 0 {
+			coins = append(coins, coin)
+		}
+	}
+	sort.Strings(coins)
+	var totalNtl float64
+	for _, coin := range coins {
+		pos := p.positions[coin]
+		mid := p.midLocked(coin)
+		lev := p.leverageLocked(coin)
+		value := math.Abs(pos.Szi) * mid
+		totalNtl += value
+		entry := models.AssetPosition{Type: "oneWay"}
+		entry.Position.Coin = coin
+		entry.Position.Szi = pos.Szi
+		entry.Position.Leverage.Type = p.leverageTypeLocked(coin)
+		entry.Position.Leverage.Value = lev
+		entry.Position.EntryPx = pos.EntryPx
+		entry.Position.PositionValue = value
+		entry.Position.UnrealizedPnl = pos.Szi * (mid - pos.EntryPx)
+		entry.Position.MarginUsed = value / float64(lev)
+		entry.Position.MaxLeverage = lev
+		if pos.EntryPx > 0 {
+			entry.Position.ReturnOnEquity = entry.Position.UnrealizedPnl / (math.Abs(pos.Szi) * pos.EntryPx / float64(lev))
+		}
+		state.AssetPositions = append(state.AssetPositions, entry)
+	}
+	summary := models.MarginSummary{
+		AccountValue:    accountValue,
+		TotalNtlPos:     totalNtl,
+		TotalRawUsd:     p.balance,
+		TotalMarginUsed: marginUsed,
+	}
+	state.MarginSummary = summary
+	state.CrossMarginSummary = summary
+	state.Withdrawable = math.Max(accountValue-marginUsed, 0)
+
+	for _, order := range p.resting {
+		wd2.Data.OpenOrders = append(wd2.Data.OpenOrders, order)
+	}
+	sort.Slice(wd2.Data.OpenOrders, func(i, j int) bool {
+		return wd2.Data.OpenOrders[i].Oid < wd2.Data.OpenOrders[j].Oid
+	})
+	return wd2
+}
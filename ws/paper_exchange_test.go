@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/fakehl"
+	"github.com/itay747/hyperformance/models"
+)
+
+// newPaperTestManager builds a dry run Manager trading BTC at a 100000 mid, its meta
+// served by fakehl.
+func newPaperTestManager(t *testing.T) *Manager {
+	t.Helper()
+	srv := fakehl.New(hl.Asset{Name: "BTC", SzDecimals: 5, MaxLeverage: 50})
+	t.Cleanup(srv.Close)
+	t.Cleanup(srv.InstallTransport())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	manager := newPaperManager(ctx, &config.HyperformanceConfig{
+		CopyAddress: testCopyAddress,
+		CoinRiskMap: map[string]float64{"BTC": 1},
+	})
+	manager.AssetCtxStore.Store("BTC", models.AssetCtx{MidPx: 100000})
+	return manager
+}
+
+func order(isBuy bool, sz, limitPx float64, tif, cloid string) hl.OrderRequest {
+	return hl.OrderRequest{
+		Coin:      "BTC",
+		IsBuy:     isBuy,
+		Sz:        sz,
+		LimitPx:   limitPx,
+		OrderType: hl.OrderType{Limit: &hl.LimitOrderType{Tif: tif}},
+		Cloid:     cloid,
+	}
+}
+
+func TestPaperFillsWalkTheBook(t *testing.T) {
+	manager := newPaperTestManager(t)
+	manager.Books.Update(testBook(t, "BTC", [][2]float64{{99990, 1}}, [][2]float64{{100010, 0.01}, {100020, 0.02}}))
+
+	resp, _ := manager.Paper.BulkOrders([]hl.OrderRequest{
+		order(true, 0.02, 100015, hl.TifIoc, "0x01"),
+		order(true, 0.02, 100025, hl.TifGtc, "0x02"),
+	}, hl.GroupingNa)
+	statuses := resp.Response.Data.Statuses
+	// the IOC takes the first level and drops the rest
+	if got := statuses[0].Filled; got.TotalSz != 0.01 || got.AvgPx != 100010 {
+		t.Errorf("ioc filled %v @ %v, want 0.01 @ 100010", got.TotalSz, got.AvgPx)
+	}
+	// the book isn't depleted between orders, so the GTC takes both levels
+	wantPx := (0.01*100010 + 0.01*100020) / 0.02
+	if got := statuses[1].Filled; got.TotalSz != 0.02 || math.Abs(got.AvgPx-wantPx) > 1e-6 {
+		t.Errorf("gtc filled %v @ %v, want 0.02 @ %v", got.TotalSz, got.AvgPx, wantPx)
+	}
+}
+
+func TestPaperCrossingModifyKeepsTheOrder(t *testing.T) {
+	manager := newPaperTestManager(t)
+	manager.Books.Update(testBook(t, "BTC", [][2]float64{{99990, 1}}, [][2]float64{{100010, 1}}))
+	paper := manager.Paper
+
+	if resp, _ := paper.BulkOrders([]hl.OrderRequest{order(true, 0.01, 99980, hl.TifAlo, "0x01")}, hl.GroupingNa); resp.Response.Data.Statuses[0].Error != "" {
+		t.Fatalf("alo rejected: %s", resp.Response.Data.Statuses[0].Error)
+	}
+	resp, _ := paper.BulkModifyOrdersByCloid([]hl.OrderRequest{order(true, 0.01, 100010, hl.TifAlo, "0x01")})
+	if resp.Response.Data.Statuses[0].Error == "" {
+		t.Fatal("crossing alo modify accepted")
+	}
+	resting, ok := paper.resting["0x01"]
+	if !ok || resting.LimitPx != 99980 {
+		t.Fatalf("after a rejected modify resting = %+v, want the order at 99980", resting)
+	}
+}
+
+func TestPaperPublishesThroughFrames(t *testing.T) {
+	manager := newPaperTestManager(t)
+	resp, _ := manager.Paper.BulkOrders([]hl.OrderRequest{order(true, 0.01, 101000, hl.TifIoc, "0x01")}, hl.GroupingNa)
+	if resp.Response.Data.Statuses[0].Error != "" {
+		t.Fatalf("ioc rejected: %s", resp.Response.Data.Statuses[0].Error)
+	}
+	frames := manager.Paper.frames()
+	if len(frames) != 2 {
+		t.Fatalf("published %d frames, want webData2 and one activeAssetData", len(frames))
+	}
+	for _, frame := range frames {
+		manager.handleWsRx(frame)
+	}
+	if !manager.PasteWebSocketReady || !manager.PasteAssetDataReady {
+		t.Fatal("paper frames didn't make the paste side ready")
+	}
+	if szi := manager.PasteWd2.PositionsByCoin()["BTC"].Szi; szi != 0.01 {
+		t.Errorf("published BTC szi = %v, want 0.01", szi)
+	}
+	if maxTradeSz, ok := manager.maxTradeSz(hl.Order{Coin: "BTC", Side: "B"}); !ok || maxTradeSz <= 0 {
+		t.Errorf("published max trade size = %v, %v", maxTradeSz, ok)
+	}
+}
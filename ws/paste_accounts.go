@@ -0,0 +1,127 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/gorilla/websocket"
+	"github.com/itay747/hyperformance/models"
+)
+
+// StartEngines wires the wd2 and order update streams into the ALO, IOC, trigger and fill
+// engines of this Manager and of every child paste account.
+func (manager *Manager) StartEngines(ctx context.Context) {
+	copyWd2Pipeline := NewPipeline(manager.CopyWd2Chan)
+	pasteWd2Pipeline := NewPipeline(manager.PasteWd2Chan)
+	orderUpdatesPipeline := NewPipeline(manager.OrderUpdatesChan)
+
+	copyTees := copyWd2Pipeline.Tee(3)
+	pasteTees := pasteWd2Pipeline.Tee(2)
+
+	iocCopyWd2Chan := copyTees[0]
+	iocPasteWd2Chan := pasteTees[0]
+
+	aloCopyWd2Chan := copyTees[1]
+	aloPasteWd2Chan := pasteTees[1]
+
+	manager.AloEngine.Start(ctx,
+		aloCopyWd2Chan.Out(),
+		aloPasteWd2Chan.Out(),
+		manager.L2BookSnapshotChan)
+	manager.IocEngine.Start(ctx,
+		iocCopyWd2Chan.Out(),
+		iocPasteWd2Chan.Out(),
+		manager.LeaderWd2Chan,
+		orderUpdatesPipeline.Out())
+	manager.TriggerEngine.Start(ctx, copyTees[2].Out())
+	manager.FillEngine.Start(ctx, manager.UserFillsChan)
+
+	for _, child := range manager.Children {
+		child.StartEngines(ctx)
+	}
+}
+
+// subscribeChildStreams subscribes each child's paste webData2, activeAssetData and
+// orderUpdates on the shared connection. Order updates carry no user, so
+// routeOrderUpdates hands each child its own.
+func (manager *Manager) subscribeChildStreams(connection *websocket.Conn) error {
+	for _, child := range manager.Children {
+		if child.Paper != nil {
+			continue
+		}
+		for _, coinSymbol := range child.streamSymbols {
+			userCoin := models.SubscriptionPayload{Coin: coinSymbol, User: child.PasteAddress}
+			if err := connection.WriteJSON(models.NewSubcriptionRequest("activeAssetData", userCoin)); err != nil {
+				return err
+			}
+		}
+		userCoin := models.SubscriptionPayload{User: child.PasteAddress}
+		if err := connection.WriteJSON(models.NewSubcriptionRequest("webData2", userCoin)); err != nil {
+			return err
+		}
+		if err := connection.WriteJSON(models.NewSubcriptionRequest("orderUpdates", userCoin)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forwardToChildren hands a raw webData2, activeAssetData, l2Book or userFills frame to
+// every child, which keeps the copy and leader frames, its own paste frames and every book.
+func (manager *Manager) forwardToChildren(rawData []byte) {
+	for _, child := range manager.Children {
+		child.handleWsRx(rawData)
+	}
+}
+
+// routeOrderUpdates splits an orderUpdates message between this Manager and its
+// children. The message carries no user, and the paste accounts' updates arrive on it
+// with the copy ones, so each paste update goes to the accounts that claim it and every
+// other update to all of them. Children that fall behind lose updates rather than stall
+// the frame handler.
+func (manager *Manager) routeOrderUpdates(orderUpdates *models.OrderMessage) {
+	accounts := append([]*Manager{manager}, manager.Children...)
+	shares := make([][]models.OrderUpdate, len(accounts))
+	for _, update := range orderUpdates.Data {
+		claimed := false
+		for i, account := range accounts {
+			if account.claimsOrderUpdate(update) {
+				shares[i] = append(shares[i], update)
+				claimed = true
+			}
+		}
+		if claimed {
+			continue
+		}
+		for i := range accounts {
+			shares[i] = append(shares[i], update)
+		}
+	}
+	if len(shares[0]) > 0 {
+		manager.OrderUpdatesChan <- &models.OrderMessage{Channel: orderUpdates.Channel, Data: shares[0]}
+	}
+	for i, child := range manager.Children {
+		share := shares[i+1]
+		if len(share) == 0 || !child.IsReady() {
+			continue
+		}
+		select {
+		case child.OrderUpdatesChan <- &models.OrderMessage{Channel: orderUpdates.Channel, Data: share}:
+		default:
+			logger.LogWarnf("[routeOrderUpdates] paste %s order updates queue full => dropping %d updates", child.PasteAddress, len(share))
+		}
+	}
+}
+
+// claimsOrderUpdate reports whether update is of an order of this paste account: an IOC
+// still in flight under this oid, or an order resting in the latest paste snapshot.
+func (manager *Manager) claimsOrderUpdate(update models.OrderUpdate) bool {
+	order := update.Order
+	if manager.IocInFlight.Claims(order.Coin, order.Cloid, order.Oid) {
+		return true
+	}
+	if manager.PasteWd2 == nil {
+		return false
+	}
+	_, found := manager.PasteWd2.OrdersByOid()[order.Oid]
+	return found
+}
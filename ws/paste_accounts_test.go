@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+)
+
+// newTestChildren builds a parent trading for another paste account and a ready child
+// trading for testPasteAddress.
+func newTestChildren(t *testing.T) (parent, child *Manager) {
+	t.Helper()
+	parent, _ = newTestManager(t, func(cfg *config.HyperformanceConfig) {
+		cfg.PasteAddress = "0x4444444444444444444444444444444444444444"
+	})
+	child, _ = newTestManager(t, nil)
+	child.isChild = true
+	parent.Children = append(parent.Children, child)
+	feed(t, child, 1000, 100000, nil, nil)
+	return parent, child
+}
+
+func TestOrderUpdatesRouteToTheAccountThatSentThem(t *testing.T) {
+	parent, child := newTestChildren(t)
+	// both accounts mirror copy oid 42, so both mint the same cloid
+	mirror := parent.NewPasteCloid(cloid.EngineIoc, primaryLeader, 42)
+	parent.IocInFlight.Submit("BTC", mirror, 0.1)
+	parent.IocInFlight.Bind("BTC", mirror, 1)
+	child.IocInFlight.Submit("BTC", mirror, 0.05)
+	child.IocInFlight.Bind("BTC", mirror, 2)
+
+	childFill := models.OrderUpdate{Status: "filled", StatusTimestamp: 1000, Order: hl.Order{Coin: "BTC", Side: "B", Oid: 2, Cloid: mirror, OrigSz: 0.05}}
+	copyFill := models.OrderUpdate{Status: "filled", StatusTimestamp: 1000, Order: hl.Order{Coin: "BTC", Side: "B", Oid: 42, OrigSz: 0.1}}
+	parent.routeOrderUpdates(&models.OrderMessage{Channel: "orderUpdates", Data: []models.OrderUpdate{childFill, copyFill}})
+
+	parentShare := <-parent.OrderUpdatesChan
+	if len(parentShare.Data) != 1 || parentShare.Data[0].Order.Oid != 42 {
+		t.Errorf("parent got %+v, want the copy fill only", parentShare.Data)
+	}
+	childShare := <-child.OrderUpdatesChan
+	if len(childShare.Data) != 2 {
+		t.Errorf("child got %+v, want its own fill and the copy fill", childShare.Data)
+	}
+}
+
+func TestOrderUpdatesDropForAChildThatFallsBehind(t *testing.T) {
+	parent, child := newTestChildren(t)
+	for len(child.OrderUpdatesChan) < cap(child.OrderUpdatesChan) {
+		child.OrderUpdatesChan <- &models.OrderMessage{}
+	}
+	copyFill := models.OrderUpdate{Status: "filled", Order: hl.Order{Coin: "BTC", Side: "B", Oid: 42, OrigSz: 0.1}}
+	done := make(chan struct{})
+	go func() {
+		parent.routeOrderUpdates(&models.OrderMessage{Channel: "orderUpdates", Data: []models.OrderUpdate{copyFill}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a full child queue blocked the parent")
+	}
+	if len(parent.OrderUpdatesChan) != 1 {
+		t.Errorf("parent queued %d messages, want the copy fill", len(parent.OrderUpdatesChan))
+	}
+}
@@ -0,0 +1,304 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	recorderFlushEvery = time.Second
+)
+
+// RecordedFrame is one line of a recording: the raw websocket frame and when it was read.
+type RecordedFrame struct {
+	Ts    int64           `json:"ts"`
+	Frame json.RawMessage `json:"frame"`
+}
+
+// Recorder appends raw websocket frames to a gzip compressed JSON lines file. Every run
+// appends a new gzip member, which gzip readers treat as one continuous stream. A member
+// left open by a crash is closed off by the next NewRecorder, keeping what was flushed.
+type Recorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	gz        *gzip.Writer
+	enc       *json.Encoder
+	lastFlush time.Time
+	closed    bool
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open recording %s: %w", path, err)
+	}
+	if err := repairRecording(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("repair recording %s: %w", path, err)
+	}
+	gz := gzip.NewWriter(file)
+	return &Recorder{
+		file:      file,
+		gz:        gz,
+		enc:       json.NewEncoder(gz),
+		lastFlush: time.Now(),
+	}, nil
+}
+
+// Record appends frame, flushing to disk at most once per recorderFlushEvery.
+func (r *Recorder) Record(frame []byte) error {
+	if !json.Valid(frame) {
+		return fmt.Errorf("frame is not valid json")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errors.New("recorder closed")
+	}
+	now := time.Now()
+	if err := r.enc.Encode(RecordedFrame{Ts: now.UnixMilli(), Frame: frame}); err != nil {
+		return err
+	}
+	if now.Sub(r.lastFlush) >= recorderFlushEvery {
+		r.lastFlush = now
+		return r.gz.Flush()
+	}
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	gzErr := r.gz.Close()
+	fileErr := r.file.Close()
+	return errors.Join(gzErr, fileErr)
+}
+
+// repairRecording closes off a trailing gzip member that a crashed run never finished:
+// its complete lines are written back as a finished member in its place. Frames after
+// the last flush are lost.
+func repairRecording(file *os.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	counter := &countingReader{r: bufio.NewReader(file)}
+	for {
+		memberStart := counter.n
+		gz, err := gzip.NewReader(counter)
+		if err == io.EOF {
+			return nil
+		}
+		var frames []byte
+		if err == nil {
+			gz.Multistream(false)
+			frames, err = io.ReadAll(gz)
+			if err == nil {
+				continue
+			}
+		}
+		frames = frames[:bytes.LastIndexByte(frames, '\n')+1]
+		logger.LogWarnf("[Recorder] copy recording ends in a truncated member (%v) => keeping %d bytes of frames", err, len(frames))
+		if err := file.Truncate(memberStart); err != nil {
+			return err
+		}
+		if len(frames) == 0 {
+			return nil
+		}
+		repaired := gzip.NewWriter(file)
+		if _, err := repaired.Write(frames); err != nil {
+			return err
+		}
+		return repaired.Close()
+	}
+}
+
+// countingReader counts the bytes read through it. It is a ByteReader, so gzip reads no
+// further than the end of each member.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// Replay handles a recording's frames on the calling goroutine, keeping the recorded
+// spacing between frames divided by speed. A speed <= 0 replays as fast as possible. The
+// recorded frames of the paste accounts are dropped: the PaperExchanges publish the paste
+// side instead, stepped on the recorded timestamps every paperTickEvery, and after every
+// frame until the paste side is ready, so a replay plays out the same at any speed.
+// A recording cut short by a crash replays up to its last complete frame.
+func (manager *Manager) Replay(ctx context.Context, path string, speed float64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open recording %s: %w", path, err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("read recording %s: %w", path, err)
+	}
+	defer gz.Close()
+
+	reader := bufio.NewReaderSize(gz, 1024*1024)
+	var prevTs, steppedTs int64
+	frames := 0
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr == io.ErrUnexpectedEOF {
+			logger.LogWarnf("[Replay] copy %s ends in a truncated member => stopping after %d frames", path, frames)
+			return nil
+		}
+		if len(line) > 0 {
+			var recorded RecordedFrame
+			if err := json.Unmarshal(line, &recorded); err != nil {
+				return fmt.Errorf("frame %d: %w", frames+1, err)
+			}
+			if speed > 0 && prevTs != 0 && recorded.Ts > prevTs {
+				wait := time.Duration(float64(recorded.Ts-prevTs)/speed) * time.Millisecond
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			} else if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			prevTs = recorded.Ts
+			if frame, keep := manager.replayFrame(recorded.Frame); keep {
+				manager.handleWsRx(frame)
+			}
+			if !manager.pasteReady() || recorded.Ts-steppedTs >= paperTickEvery.Milliseconds() {
+				if manager.stepPaper(recorded.Ts) {
+					steppedTs = recorded.Ts
+				}
+			}
+			frames++
+		}
+		if readErr == io.EOF {
+			logger.LogInfof("[Replay] copy finished %s => %d frames", path, frames)
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// stepPaper steps the PaperExchanges of this Manager and its children to now and handles
+// the frames they publish. It reports whether any were published.
+func (manager *Manager) stepPaper(now int64) bool {
+	papers := []*PaperExchange{manager.Paper}
+	for _, child := range manager.Children {
+		papers = append(papers, child.Paper)
+	}
+	published := false
+	for _, paper := range papers {
+		if paper == nil {
+			continue
+		}
+		for _, frame := range paper.Step(now) {
+			manager.handleWsRx(frame)
+			published = true
+		}
+	}
+	return published
+}
+
+// pasteReady reports whether the paste side of this Manager and its children is ready.
+func (manager *Manager) pasteReady() bool {
+	if !manager.PasteWebSocketReady || !manager.PasteAssetDataReady {
+		return false
+	}
+	for _, child := range manager.Children {
+		if !child.pasteReady() {
+			return false
+		}
+	}
+	return true
+}
+
+// replayFrame drops a recorded frame of a paste account of this Manager or a child:
+// webData2 and activeAssetData by user, and orderUpdates entries with this bot's cloids.
+func (manager *Manager) replayFrame(frame []byte) ([]byte, bool) {
+	var envelope struct {
+		Channel string          `json:"channel"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(frame, &envelope) != nil {
+		return frame, true
+	}
+	switch envelope.Channel {
+	case "webData2", "activeAssetData":
+		var data struct {
+			User string `json:"user"`
+		}
+		if json.Unmarshal(envelope.Data, &data) != nil {
+			return frame, true
+		}
+		return frame, !manager.isPasteAddress(data.User)
+	case "orderUpdates":
+		var updates []json.RawMessage
+		if json.Unmarshal(envelope.Data, &updates) != nil {
+			return frame, true
+		}
+		kept := updates[:0]
+		for _, update := range updates {
+			var entry struct {
+				Order struct {
+					Cloid string `json:"cloid"`
+				} `json:"order"`
+			}
+			if json.Unmarshal(update, &entry) == nil && manager.OwnsCloid(entry.Order.Cloid) {
+				continue
+			}
+			kept = append(kept, update)
+		}
+		if len(kept) == 0 {
+			return nil, false
+		}
+		filtered, err := json.Marshal(map[string]any{"channel": envelope.Channel, "data": kept})
+		if err != nil {
+			return frame, true
+		}
+		return filtered, true
+	}
+	return frame, true
+}
+
+func (manager *Manager) isPasteAddress(address string) bool {
+	if strings.EqualFold(address, manager.PasteAddress) {
+		return true
+	}
+	for _, child := range manager.Children {
+		if child.isPasteAddress(address) {
+			return true
+		}
+	}
+	return false
+}
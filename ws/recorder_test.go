@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/models"
+)
+
+// writeRecording writes frames as a recording, stamping each with its ts.
+func writeRecording(t *testing.T, frames []RecordedFrame) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.jsonl.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	enc := json.NewEncoder(gz)
+	for _, frame := range frames {
+		if err := enc.Encode(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplayRunsThePaperOnTheRecordedClock(t *testing.T) {
+	manager := newPaperTestManager(t)
+	resp, _ := manager.Paper.BulkOrders([]hl.OrderRequest{order(true, 0.01, 99500, hl.TifAlo, "0x01")}, hl.GroupingNa)
+	if resp.Response.Data.Statuses[0].Error != "" {
+		t.Fatalf("alo rejected: %s", resp.Response.Data.Statuses[0].Error)
+	}
+
+	copyAssetData := models.WireActiveAssetData{User: testCopyAddress, Coin: "BTC", MaxTradeSzs: []string{"1", "1"}, AvailableToTrade: []string{"10000", "10000"}}
+	copyAssetData.Leverage.Type = "cross"
+	copyAssetData.Leverage.Value = 10
+	assetDataFrame, _ := json.Marshal(map[string]any{"channel": "activeAssetData", "data": copyAssetData})
+	orderUpdatesFrame, _ := json.Marshal(models.OrderMessage{Channel: "orderUpdates", Data: []models.OrderUpdate{{
+		Status:          "open",
+		StatusTimestamp: 1001,
+		Order:           hl.Order{Coin: "BTC", Side: "B", LimitPx: 99000, Sz: 0.1, OrigSz: 0.1, Oid: 7, Timestamp: 1001},
+	}}})
+	path := writeRecording(t, []RecordedFrame{
+		{Ts: 1000, Frame: assetDataFrame},
+		{Ts: 1000, Frame: testWd2Frame(t, testCopyAddress, 1000, 100000, nil)},
+		{Ts: 1001, Frame: orderUpdatesFrame},
+		// the mid trades through the ALO here, but the paper only fills it on its next step
+		{Ts: 1200, Frame: testWd2Frame(t, testCopyAddress, 1200, 99000, nil)},
+		{Ts: 1600, Frame: testWd2Frame(t, testCopyAddress, 1600, 99000, nil)},
+	})
+
+	if err := manager.Replay(context.Background(), path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.OrderUpdatesChan) != 1 {
+		t.Errorf("%d orderUpdates handled, want the one replayed after the paste side was ready", len(manager.OrderUpdatesChan))
+	}
+	var published []int64
+	for len(manager.PasteWd2Chan) > 0 {
+		wd2 := <-manager.PasteWd2Chan
+		published = append(published, wd2.Data.ServerTime)
+	}
+	if len(published) != 2 || published[0] != 1000 || published[1] != 1600 {
+		t.Errorf("paper published at %v, want the recorded 1000 and 1600", published)
+	}
+	if szi := manager.PasteWd2.PositionsByCoin()["BTC"].Szi; szi != 0.01 {
+		t.Errorf("paste BTC szi after replay = %v, want the ALO filled", szi)
+	}
+}
@@ -0,0 +1,187 @@
+package ws
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/utils"
+)
+
+// RiskReason is the reason code a RiskGate rejection is logged and counted under.
+type RiskReason string
+
+const (
+	RiskMargin           RiskReason = "margin"
+	RiskMaxTradeSz       RiskReason = "max_trade_sz"
+	RiskOrderNotional    RiskReason = "order_notional"
+	RiskPositionNotional RiskReason = "position_notional"
+	RiskAccountLeverage  RiskReason = "account_leverage"
+	RiskRateLimit        RiskReason = "rate_limit"
+)
+
+// RiskGate is the last check before paste orders are sent. Orders that only reduce the
+// paste position skip the margin, size and exposure checks, but still count towards the
+// orders per minute.
+type RiskGate struct {
+	mu         sync.Mutex
+	manager    *Manager
+	limits     config.RiskConfig
+	rate       *rateLimit
+	rejections map[RiskReason]int
+}
+
+// rateLimit is the send times of the last minute. The gates of a Manager and its
+// children share one, as their orders share the exchange's rate limit.
+type rateLimit struct {
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+func NewRiskGate(manager *Manager, limits config.RiskConfig) *RiskGate {
+	return &RiskGate{
+		manager:    manager,
+		limits:     limits,
+		rate:       &rateLimit{},
+		rejections: make(map[RiskReason]int),
+	}
+}
+
+// shareRateLimit makes g count its orders per minute together with parent's.
+func (g *RiskGate) shareRateLimit(parent *RiskGate) {
+	g.rate = parent.rate
+}
+
+// Filter returns the requests that pass every check, in order. Each check runs against
+// the paste position as it would be after the requests allowed before it.
+func (g *RiskGate) Filter(requests []hl.OrderRequest) []hl.OrderRequest {
+	manager := g.manager
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	projected := make(map[string]float64)
+	var totalNotional, accountValue float64
+	if manager.PasteWd2 != nil {
+		for coin, position := range manager.PasteWd2.PositionsByCoin() {
+			projected[coin] = position.Szi
+		}
+		totalNotional = manager.PasteWd2.Data.ClearinghouseState.MarginSummary.TotalNtlPos
+		accountValue = manager.PasteWd2.AccountValue()
+	}
+
+	now := time.Now()
+	g.rate.mu.Lock()
+	defer g.rate.mu.Unlock()
+	g.rate.expireLocked(now)
+	allowed := make([]hl.OrderRequest, 0, len(requests))
+	for _, request := range requests {
+		signedSz := request.Sz
+		if !request.IsBuy {
+			signedSz = -signedSz
+		}
+		price := manager.GetMidPrice(request.Coin)
+		if price <= 0 {
+			price = request.LimitPx
+		}
+		before := projected[request.Coin]
+		after := before + signedSz
+		reason, detail := g.checkLocked(request, price, before, after, totalNotional, accountValue)
+		if reason != "" {
+			g.rejections[reason]++
+			riskRejections.Inc(string(reason), request.Coin)
+			logger.LogFields("warn", fmt.Sprintf("[Risk] paste rejected %s %s sz=%v reason=%s %s",
+				request.Coin, sideName(request.IsBuy), request.Sz, reason, detail), utils.RequestFields(request))
+			continue
+		}
+		g.rate.sent = append(g.rate.sent, now)
+		projected[request.Coin] = after
+		totalNotional += (math.Abs(after) - math.Abs(before)) * price
+		allowed = append(allowed, request)
+	}
+	return allowed
+}
+
+func (g *RiskGate) checkLocked(request hl.OrderRequest, price, before, after, totalNotional, accountValue float64) (RiskReason, string) {
+	if g.limits.MaxOrdersPerMinute > 0 && len(g.rate.sent) >= g.limits.MaxOrdersPerMinute {
+		return RiskRateLimit, fmt.Sprintf("%d orders in the last minute", len(g.rate.sent))
+	}
+	reducing := request.ReduceOnly || math.Abs(after) < math.Abs(before) && after*before >= 0
+	if reducing {
+		return "", ""
+	}
+	orderNotional := request.Sz * price
+	if g.limits.MaxOrderNotional > 0 && orderNotional > g.limits.MaxOrderNotional {
+		return RiskOrderNotional, fmt.Sprintf("$%.2f > $%.2f", orderNotional, g.limits.MaxOrderNotional)
+	}
+	if maxNotional := g.maxPositionNotional(request.Coin); maxNotional > 0 && math.Abs(after)*price > maxNotional {
+		return RiskPositionNotional, fmt.Sprintf("$%.2f > $%.2f", math.Abs(after)*price, maxNotional)
+	}
+	if g.limits.MaxAccountLeverage > 0 && accountValue > 0 {
+		leverage := (totalNotional + (math.Abs(after)-math.Abs(before))*price) / accountValue
+		if leverage > g.limits.MaxAccountLeverage {
+			return RiskAccountLeverage, fmt.Sprintf("%.2fx > %.2fx", leverage, g.limits.MaxAccountLeverage)
+		}
+	}
+	side := "B"
+	if !request.IsBuy {
+		side = "A"
+	}
+	order := hl.Order{Coin: request.Coin, Side: side, Sz: request.Sz}
+	if maxTradeSz, ok := g.manager.maxTradeSz(order); ok && request.Sz > maxTradeSz {
+		return RiskMaxTradeSz, fmt.Sprintf("%v > %v", request.Sz, maxTradeSz)
+	}
+	if !g.manager.HasMargin(order) {
+		return RiskMargin, ""
+	}
+	return "", ""
+}
+
+func (g *RiskGate) maxPositionNotional(coin string) float64 {
+	if maxNotional, ok := g.limits.MaxPositionNotional[coin]; ok {
+		return maxNotional
+	}
+	return g.limits.MaxPositionNotional["*"]
+}
+
+func (r *rateLimit) expireLocked(now time.Time) {
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(r.sent) && r.sent[i].Before(cutoff) {
+		i++
+	}
+	r.sent = r.sent[i:]
+}
+
+// Rejections returns the rejection count per reason since start.
+func (g *RiskGate) Rejections() map[RiskReason]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	counts := make(map[RiskReason]int, len(g.rejections))
+	for reason, n := range g.rejections {
+		counts[reason] = n
+	}
+	return counts
+}
+
+// Summary renders the rejection counts as "reason:n ...", or "" if there were none.
+func (g *RiskGate) Summary() string {
+	counts := g.Rejections()
+	parts := make([]string, 0, len(counts))
+	for reason, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s:%d", reason, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func sideName(isBuy bool) string {
+	if isBuy {
+		return "BUY"
+	}
+	return "SELL"
+}
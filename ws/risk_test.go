@@ -0,0 +1,46 @@
+package ws
+
+import (
+	"testing"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+)
+
+func TestRiskGateRejectsOrdersBeyondTheMargin(t *testing.T) {
+	manager, _ := newTestManager(t, nil)
+	feed(t, manager, 1000, 100000, nil, nil)
+
+	// $10000 free at 10x buys $100000 of BTC
+	within := order(true, 0.5, 100000, hl.TifIoc, "")
+	beyond := order(true, 2, 100000, hl.TifIoc, "")
+	allowed := manager.RiskGate.Filter([]hl.OrderRequest{beyond, within})
+	if len(allowed) != 1 || allowed[0].Sz != 0.5 {
+		t.Fatalf("allowed %+v, want only the 0.5 BTC order", allowed)
+	}
+	if rejected := manager.RiskGate.Rejections()[RiskMargin]; rejected != 1 {
+		t.Errorf("margin rejections = %d, want 1", rejected)
+	}
+}
+
+func TestPasteAccountsShareTheOrderRateLimit(t *testing.T) {
+	limits := func(cfg *config.HyperformanceConfig) {
+		cfg.Risk = config.RiskConfig{MaxOrdersPerMinute: 2}
+	}
+	parent, _ := newTestManager(t, limits)
+	child, _ := newTestManager(t, limits)
+	child.RiskGate.shareRateLimit(parent.RiskGate)
+	feed(t, parent, 1000, 100000, nil, nil)
+	feed(t, child, 1000, 100000, nil, nil)
+
+	small := order(true, 0.01, 100000, hl.TifIoc, "")
+	if allowed := parent.RiskGate.Filter([]hl.OrderRequest{small, small}); len(allowed) != 2 {
+		t.Fatalf("parent allowed %d orders, want 2", len(allowed))
+	}
+	if allowed := child.RiskGate.Filter([]hl.OrderRequest{small}); len(allowed) != 0 {
+		t.Errorf("child allowed %d orders after the parent used the limit, want 0", len(allowed))
+	}
+	if rejected := child.RiskGate.Rejections()[RiskRateLimit]; rejected != 1 {
+		t.Errorf("child rate limit rejections = %d, want 1", rejected)
+	}
+}
@@ -0,0 +1,121 @@
+package ws
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/fakehl"
+	"github.com/itay747/hyperformance/models"
+)
+
+// a throwaway key; fakehl doesn't verify signatures
+const testSecretKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+// sessionWebData2 is the data of a webData2 frame, as fakehl pushes it.
+func sessionWebData2(user string, chTime int64, midPx string) map[string]any {
+	return map[string]any{
+		"user":       user,
+		"serverTime": chTime,
+		"clearinghouseState": map[string]any{
+			"marginSummary":  map[string]any{"accountValue": "10000", "totalNtlPos": "0", "totalRawUsd": "10000", "totalMarginUsed": "0"},
+			"assetPositions": []any{},
+			"time":           chTime,
+		},
+		"openOrders": []any{},
+		"assetCtxs":  []any{map[string]any{"midPx": midPx, "markPx": midPx, "oraclePx": midPx}},
+	}
+}
+
+func sessionAssetData(user, coin string) models.WireActiveAssetData {
+	data := models.WireActiveAssetData{
+		User:             user,
+		Coin:             coin,
+		MaxTradeSzs:      []string{"10", "10"},
+		AvailableToTrade: []string{"10000", "10000"},
+	}
+	data.Leverage.Type = "cross"
+	data.Leverage.Value = 10
+	return data
+}
+
+// TestSessionMirrorsCopyFill runs a live session against fakehl and checks that a
+// filled copy market order is pasted as an IOC priced off the copy fill, not the mid.
+func TestSessionMirrorsCopyFill(t *testing.T) {
+	srv := fakehl.New(hl.Asset{Name: "BTC", SzDecimals: 5, MaxLeverage: 50})
+	defer srv.Close()
+	restore := srv.InstallTransport()
+	defer restore()
+	srv.SetMids(map[string]string{"BTC": "100000"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg := &config.HyperformanceConfig{
+		SecretKey:     testSecretKey,
+		CopyAddress:   testCopyAddress,
+		PasteAddress:  testPasteAddress,
+		CoinRiskMap:   map[string]float64{"BTC": 1},
+		WsEndpoint:    srv.WsURL,
+		StartupPolicy: config.StartupReconcile,
+	}
+	manager := NewManager(ctx, cfg, ManagerOptions{})
+	manager.StartEngines(ctx)
+	go manager.StartCopyTradingSession(ctx)
+
+	// activeAssetData, webData2 and orderUpdates for both accounts, userFills and l2Book
+	if err := srv.WaitForSubscriptions(ctx, 8); err != nil {
+		t.Fatalf("subscriptions: %v", err)
+	}
+	for _, user := range []string{testPasteAddress, testCopyAddress} {
+		srv.PushActiveAssetData(sessionAssetData(user, "BTC"))
+		srv.Push("webData2", user, "", sessionWebData2(user, 1000, "100000"))
+	}
+	srv.Push("userFills", testCopyAddress, "", map[string]any{
+		"user": testCopyAddress,
+		"fills": []any{map[string]any{
+			"coin": "BTC", "px": "99000", "sz": "0.01", "side": "B", "oid": 42, "tid": 7, "time": 1000,
+		}},
+	})
+	copyOrder := hl.Order{Coin: "BTC", Side: "B", LimitPx: 99500, Oid: 42, OrigSz: 0.01, Sz: 0.01}
+	filledOrder := copyOrder
+	filledOrder.Sz = 0
+	srv.PushOrderUpdates(testCopyAddress, []models.OrderUpdate{
+		{Status: "open", StatusTimestamp: 1000, Order: copyOrder},
+		{Status: "filled", StatusTimestamp: 1000, Order: filledOrder},
+	})
+
+	action, err := srv.WaitForAction(ctx, "order")
+	if err != nil {
+		t.Fatalf("no paste order: %v", err)
+	}
+	var placed hl.PlaceOrderAction
+	if err := action.Decode(&placed); err != nil {
+		t.Fatal(err)
+	}
+	if len(placed.Orders) != 1 {
+		t.Fatalf("pasted %d orders, want 1", len(placed.Orders))
+	}
+	pasted := placed.Orders[0]
+	if pasted.Asset != 0 || !pasted.IsBuy || pasted.ReduceOnly {
+		t.Errorf("pasted asset=%d buy=%v reduceOnly=%v, want a BTC buy", pasted.Asset, pasted.IsBuy, pasted.ReduceOnly)
+	}
+	if sz, _ := strconv.ParseFloat(pasted.SizePx, 64); sz != 0.01 {
+		t.Errorf("pasted sz=%s, want 0.01", pasted.SizePx)
+	}
+	// 100bps of slippage over the 99000 fill, where the mid would give 101000
+	wantPx := manager.SnapPrice("BTC", 99000*(1+defaultSlippageBps/10000))
+	if px, _ := strconv.ParseFloat(pasted.LimitPx, 64); px != wantPx {
+		t.Errorf("pasted px=%s, want %v", pasted.LimitPx, wantPx)
+	}
+	id, ok := cloid.Decode(pasted.Cloid)
+	if !ok {
+		t.Fatalf("pasted cloid %s doesn't decode", pasted.Cloid)
+	}
+	if id.Engine != cloid.EngineIoc || id.Leader != primaryLeader || id.Source != 42 {
+		t.Errorf("pasted cloid %s, want the IOC engine mirroring oid 42", id.Label())
+	}
+}
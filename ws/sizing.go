@@ -0,0 +1,172 @@
+package ws
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+)
+
+// SizingFor returns the sizing mode for symbol, falling back to the "*" entry and then
+// to account_ratio.
+func (manager *Manager) SizingFor(symbol string) config.SizingConfig {
+	if manager.Config != nil {
+		if sizing, ok := manager.Config.Sizing[symbol]; ok {
+			return sizing
+		}
+		if sizing, ok := manager.Config.Sizing["*"]; ok {
+			return sizing
+		}
+	}
+	return config.SizingConfig{Mode: config.SizingAccountRatio}
+}
+
+// SizingLabel is a short description of the sizing applied to symbol, for the TUI.
+func (manager *Manager) SizingLabel(symbol string) string {
+	sizing := manager.SizingFor(symbol)
+	switch sizing.Mode {
+	case config.SizingFixedMultiplier:
+		return fmt.Sprintf("mult %.2fx", sizing.Value*manager.PasteScale)
+	case config.SizingFixedNotional:
+		return fmt.Sprintf("$%.0f/trade", sizing.Value*manager.PasteScale)
+	case config.SizingMarginMatch:
+		return "margin"
+	case config.SizingCappedLeverage:
+		return fmt.Sprintf("cap %vx", sizing.Value)
+	default:
+		return fmt.Sprintf("ratio %.2fx", manager.CoinRiskMap()[symbol]*manager.PasteScale)
+	}
+}
+
+// sizeFor converts a signed leader position on symbol into the signed paste position.
+//
+//   - account_ratio:    paste/leader account value times the coin risk
+//   - fixed_multiplier: the leader position times Value
+//   - margin_match:     account_ratio adjusted by paste/leader leverage, so the paste
+//     margin used is the same share of equity as the leader's
+//   - capped_leverage:  account_ratio; targetSzi caps the blended position
+//
+// fixed_notional sizes trades rather than positions; see primarySize and heldSzi.
+func (manager *Manager) sizeFor(leader *Leader, symbol string, leaderSzi float64) float64 {
+	if leaderSzi == 0 {
+		return 0
+	}
+	sizing := manager.SizingFor(symbol)
+	switch sizing.Mode {
+	case config.SizingFixedMultiplier:
+		return leaderSzi * sizing.Value * manager.PasteScale
+	case config.SizingMarginMatch:
+		return leaderSzi * manager.scaleFactorFor(leader.Wd2, manager.leaderCoins(leader), symbol) * manager.leverageRatio(leader, symbol)
+	case config.SizingCappedLeverage, config.SizingAccountRatio, "":
+		return leaderSzi * manager.scaleFactorFor(leader.Wd2, manager.leaderCoins(leader), symbol)
+	default:
+		logger.LogWarnf("[sizeFor] paste unknown sizing mode %q for %s => account_ratio", sizing.Mode, symbol)
+		return leaderSzi * manager.scaleFactorFor(leader.Wd2, manager.leaderCoins(leader), symbol)
+	}
+}
+
+// targetSzi is the paste target position on symbol: every leader's position sized with
+// sizeFor, weighted, summed and capped by capPasteSize. primarySzi stands in for the
+// primary leader's position, so callers can ask what the target would be after a copy
+// order. Under fixed_notional it is heldSzi instead.
+func (manager *Manager) targetSzi(symbol string, primarySzi float64) float64 {
+	if manager.SizingFor(symbol).Mode == config.SizingFixedNotional {
+		return manager.heldSzi(symbol, primarySzi)
+	}
+	var szi float64
+	for i, leader := range manager.Leaders {
+		leaderSzi := primarySzi
+		if i > 0 {
+			if leader.Wd2 == nil {
+				continue
+			}
+			leaderSzi = leader.Wd2.PositionsByCoin()[symbol].Szi
+		}
+		szi += manager.sizeFor(leader, symbol, leaderSzi) * leader.Weight
+	}
+	return manager.capPasteSize(symbol, szi)
+}
+
+// heldSzi is the fixed_notional target on symbol. The mirrored trades, not the leader's
+// position, size the paste position, so the target is the paste position as held,
+// counting size in flight, while the primary leader holds the same side, and flat once
+// the leader is flat or has turned.
+func (manager *Manager) heldSzi(symbol string, primarySzi float64) float64 {
+	if manager.PasteWd2 == nil {
+		return 0
+	}
+	pasteSzi := manager.PasteWd2.PositionsByCoin()[symbol].Szi + manager.IocInFlight.Pending(symbol)
+	if primarySzi == 0 || (pasteSzi > 0) != (primarySzi > 0) {
+		return 0
+	}
+	return pasteSzi
+}
+
+// primarySize sizes a signed copy order of the primary leader as the change it makes to
+// the paste target, from the leader's position before the order. filledAt is when the
+// order filled, zero for an order still resting: a copy snapshot taken at or after it
+// already holds the fill. Under fixed_notional every order is Value USD in its own
+// direction; under capped_leverage nothing past the cap is mirrored.
+func (manager *Manager) primarySize(symbol string, leaderDelta float64, filledAt time.Time) float64 {
+	if manager.CopyWd2 == nil || manager.PasteWd2 == nil {
+		logger.LogErrorf("[primarySize] copy copyWd2 or pasteWd2 was nil")
+		logger.LogErrorf("[primarySize] copy copyWd2: %#+v", manager.CopyWd2)
+		logger.LogErrorf("[primarySize] paste pasteWd2: %#+v", manager.PasteWd2)
+		return 0
+	}
+	if sizing := manager.SizingFor(symbol); sizing.Mode == config.SizingFixedNotional {
+		midPrice := manager.GetMidPrice(symbol)
+		if midPrice <= 0 || leaderDelta == 0 {
+			return 0
+		}
+		return math.Copysign(sizing.Value*manager.PasteScale/midPrice, leaderDelta)
+	}
+	primarySzi := manager.CopyWd2.PositionsByCoin()[symbol].Szi
+	if !filledAt.IsZero() && !manager.CopyWd2.ClearinghouseTime().Before(filledAt) {
+		primarySzi -= leaderDelta
+	}
+	return manager.targetSzi(symbol, primarySzi+leaderDelta) - manager.targetSzi(symbol, primarySzi)
+}
+
+// capPasteSize limits the paste position szi to the capped_leverage notional, if that
+// mode is set for symbol.
+func (manager *Manager) capPasteSize(symbol string, szi float64) float64 {
+	sizing := manager.SizingFor(symbol)
+	if sizing.Mode != config.SizingCappedLeverage || sizing.Value <= 0 || manager.PasteWd2 == nil {
+		return szi
+	}
+	midPrice := manager.GetMidPrice(symbol)
+	if midPrice <= 0 {
+		return szi
+	}
+	maxSz := sizing.Value * manager.PasteWd2.AccountValue() / midPrice
+	if math.Abs(szi) > maxSz {
+		return math.Copysign(maxSz, szi)
+	}
+	return szi
+}
+
+// leverageRatio is paste leverage over leader leverage on symbol, or 1 if either is unknown.
+func (manager *Manager) leverageRatio(leader *Leader, symbol string) float64 {
+	leaderLeverage := manager.assetLeverage(leader.Address, symbol)
+	if leader.Wd2 != nil {
+		if position, ok := leader.Wd2.PositionsByCoin()[symbol]; ok && position.Leverage.Value > 0 {
+			leaderLeverage = float64(position.Leverage.Value)
+		}
+	}
+	pasteLeverage := manager.assetLeverage(manager.PasteAddress, symbol)
+	if leaderLeverage <= 0 || pasteLeverage <= 0 {
+		return 1
+	}
+	return pasteLeverage / leaderLeverage
+}
+
+func (manager *Manager) assetLeverage(address, symbol string) float64 {
+	value, ok := manager.AssetDetailsStore.Load(address + ":" + symbol)
+	if !ok {
+		return 0
+	}
+	return value.(models.AssetDetails).LeverageValue
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+)
+
+func newSizingManager(t *testing.T, sizing config.SizingConfig) *Manager {
+	t.Helper()
+	manager, _ := newTestManager(t, func(cfg *config.HyperformanceConfig) {
+		cfg.Sizing = map[string]config.SizingConfig{"BTC": sizing}
+	})
+	return manager
+}
+
+func TestSizingModesSizeAFill(t *testing.T) {
+	// the leader bought 0.05 BTC at 1500, which the copy snapshot at 2000 already holds
+	buy := hl.Order{Coin: "BTC", Side: "B", Sz: 0.05}
+	filledAt := time.UnixMilli(1500)
+	cases := []struct {
+		name   string
+		sizing config.SizingConfig
+		want   float64
+	}{
+		{"account_ratio", config.SizingConfig{Mode: config.SizingAccountRatio}, 0.05},
+		{"fixed_multiplier", config.SizingConfig{Mode: config.SizingFixedMultiplier, Value: 2}, 0.1},
+		{"fixed_notional", config.SizingConfig{Mode: config.SizingFixedNotional, Value: 1000}, 0.01},
+		{"margin_match", config.SizingConfig{Mode: config.SizingMarginMatch}, 0.05},
+		// the cap is 1x of the $10000 paste account, 0.1 BTC, which the fill reaches
+		{"capped_leverage", config.SizingConfig{Mode: config.SizingCappedLeverage, Value: 1}, 0.05},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manager := newSizingManager(t, c.sizing)
+			feed(t, manager, 2000, 100000, map[string]float64{"BTC": 0.1}, nil)
+			if got := manager.scaleFill(buy, filledAt); math.Abs(got-c.want) > 1e-9 {
+				t.Errorf("scaleFill = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCappedLeverageSizesNothingPastTheCap(t *testing.T) {
+	manager := newSizingManager(t, config.SizingConfig{Mode: config.SizingCappedLeverage, Value: 1})
+	feed(t, manager, 2000, 100000, map[string]float64{"BTC": 0.1}, nil)
+	// a resting buy would take the leader from 0.1 to 0.15, all of it past the 0.1 cap
+	if got := manager.scaleSize(hl.Order{Coin: "BTC", Side: "B", Sz: 0.05}); got != 0 {
+		t.Errorf("scaleSize past the cap = %v, want 0", got)
+	}
+	// a fill the snapshot doesn't hold yet starts from the snapshot's position
+	if got := manager.scaleFill(hl.Order{Coin: "BTC", Side: "A", Sz: 0.05}, time.UnixMilli(2500)); math.Abs(got-0.05) > 1e-9 {
+		t.Errorf("scaleFill of a later sell = %v, want 0.05", got)
+	}
+}
+
+func TestFixedNotionalSizesEveryTrade(t *testing.T) {
+	manager := newSizingManager(t, config.SizingConfig{Mode: config.SizingFixedNotional, Value: 1000})
+	feed(t, manager, 2000, 100000, map[string]float64{"BTC": 0.3}, map[string]float64{"BTC": 0.02})
+	for _, order := range []hl.Order{
+		{Coin: "BTC", Side: "B", Sz: 0.3},
+		{Coin: "BTC", Side: "A", Sz: 0.1},
+		{Coin: "BTC", Side: "B", Sz: 5},
+	} {
+		if got := manager.scaleFill(order, time.UnixMilli(1500)); math.Abs(got-0.01) > 1e-9 {
+			t.Errorf("scaleFill(%s %v) = %v, want $1000 at 100000", order.Side, order.Sz, got)
+		}
+	}
+	// the reconcile holds what the trades built while the leader is long
+	if drift := manager.GetIocReconcileOrders(manager.TargetPositions(), manager.PasteWd2.PositionsByCoin(), false, true); len(drift) != 0 {
+		t.Errorf("drift while the leader holds = %+v, want none", drift)
+	}
+	// and closes it once the leader is flat
+	feed(t, manager, 3000, 100000, nil, map[string]float64{"BTC": 0.02})
+	drift := manager.GetIocReconcileOrders(manager.TargetPositions(), manager.PasteWd2.PositionsByCoin(), false, true)
+	if len(drift) != 1 || drift[0].Side != "A" || drift[0].Sz != 0.02 || !drift[0].ReduceOnly {
+		t.Errorf("drift after the leader closed = %+v, want a 0.02 reduce only sell", drift)
+	}
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"math"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+)
+
+const (
+	defaultSlippageBps = 100.0
+	// maxBookAge is how old an l2Book snapshot can be and still price an IOC order.
+	maxBookAge = 10 * time.Second
+)
+
+// SlippageBps is the max slippage for symbol, from slippage_bps by coin or "*".
+func (manager *Manager) SlippageBps(symbol string) float64 {
+	if bps, ok := manager.Config.SlippageBps[symbol]; ok {
+		return bps
+	}
+	if bps, ok := manager.Config.SlippageBps["*"]; ok {
+		return bps
+	}
+	return defaultSlippageBps
+}
+
+// iocPrice prices an IOC order within the coin's slippage of its reference price: the
+// order's LimitPx when the caller set it to the leader's fill price, else the mid.
+// With a fresh l2Book the limit is the last level the order's size walks to; without
+// one it is the worst tolerated price. The returned size is what to send, which is
+// smaller than order.Sz only under slippage_overflow=split; ok is false to skip.
+func (manager *Manager) iocPrice(order hl.Order) (limitPx, sz float64, ok bool) {
+	referencePx := order.LimitPx
+	if referencePx <= 0 {
+		referencePx = manager.GetMidPrice(order.Coin)
+	}
+	if referencePx <= 0 {
+		return 0, 0, false
+	}
+	isBuy := order.Side == "B"
+	tolerance := manager.SlippageBps(order.Coin) / 10000
+	worstPx := referencePx * (1 - tolerance)
+	if isBuy {
+		worstPx = referencePx * (1 + tolerance)
+	}
+
+	book, fresh := manager.Books.Fresh(order.Coin, maxBookAge)
+	if !fresh {
+		return manager.SnapPrice(order.Coin, worstPx), order.Sz, true
+	}
+	levels := book.Bids()
+	if isBuy {
+		levels = book.Asks()
+	}
+	fillable, lastPx := walkBook(levels, order.Sz, worstPx, isBuy)
+	if fillable >= order.Sz {
+		return manager.SnapPrice(order.Coin, lastPx), order.Sz, true
+	}
+
+	switch manager.Config.SlippageOverflow {
+	case config.SlippageSkip:
+		logger.LogWarnf("[Slippage] paste skip %s sz=%v => book absorbs %v within %vbps of %v",
+			order.Coin, order.Sz, fillable, manager.SlippageBps(order.Coin), referencePx)
+		return 0, 0, false
+	case config.SlippageSplit:
+		fillable = hl.SizeToFloat(fillable, manager.Decimals(order.Coin))
+		if fillable <= 0 {
+			logger.LogWarnf("[Slippage] paste skip %s sz=%v => nothing within %vbps of %v",
+				order.Coin, order.Sz, manager.SlippageBps(order.Coin), referencePx)
+			return 0, 0, false
+		}
+		logger.LogWarnf("[Slippage] paste split %s sz=%v => sending %v, rest left to reconcile",
+			order.Coin, order.Sz, fillable)
+		return manager.SnapPrice(order.Coin, lastPx), fillable, true
+	default:
+		return manager.SnapPrice(order.Coin, worstPx), order.Sz, true
+	}
+}
+
+// walkBook takes levels from the top of one side until sz is filled or the next level
+// is worse than worstPx. It returns the size taken and the last price touched.
+func walkBook(levels []models.BookLevel, sz, worstPx float64, isBuy bool) (filled, lastPx float64) {
+	for _, level := range levels {
+		if isBuy && level.Price > worstPx || !isBuy && level.Price < worstPx {
+			break
+		}
+		filled += math.Min(level.Size, sz-filled)
+		lastPx = level.Price
+		if filled >= sz {
+			break
+		}
+	}
+	return filled, lastPx
+}
@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"context"
+	"time"
+)
+
+// AwaitSnapshot starts the websocket session and returns once this Manager and every
+// child is ready, with webData2 and asset data for each copy and paste account. Without
+// StartEngines nothing is sent, and the engine queues are emptied so the session never
+// blocks on them. The session keeps running until ctx is done.
+func (manager *Manager) AwaitSnapshot(ctx context.Context) error {
+	for _, account := range append([]*Manager{manager}, manager.Children...) {
+		go account.discardEngineQueues(ctx)
+	}
+	go manager.StartCopyTradingSession(ctx)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if manager.snapshotReady() {
+				return nil
+			}
+		}
+	}
+}
+
+func (manager *Manager) snapshotReady() bool {
+	if !manager.IsReady() {
+		return false
+	}
+	for _, child := range manager.Children {
+		if !child.IsReady() {
+			return false
+		}
+	}
+	return true
+}
+
+// discardEngineQueues drops what is queued for the engines until ctx is done.
+func (manager *Manager) discardEngineQueues(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-manager.CopyWd2Chan:
+		case <-manager.PasteWd2Chan:
+		case <-manager.LeaderWd2Chan:
+		case <-manager.OrderUpdatesChan:
+		case <-manager.UserFillsChan:
+		case <-manager.L2BookSnapshotChan:
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSnapshotSessionDoesNotBlockOnEngineQueues handles more copy snapshots than the
+// engine queues hold, with no engines started, as a read-only command does.
+func TestSnapshotSessionDoesNotBlockOnEngineQueues(t *testing.T) {
+	manager, _ := newTestManager(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.discardEngineQueues(ctx)
+
+	frames := 2 * cap(manager.CopyWd2Chan)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range frames {
+			manager.handleWebData2Payload(testWd2Frame(t, testCopyAddress, int64(1000+i), 100000, nil))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handling %d copy snapshots blocked on the engine queues", frames)
+	}
+}
@@ -0,0 +1,71 @@
+package ws
+
+import (
+	"fmt"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/config"
+	"github.com/itay747/hyperformance/models"
+)
+
+// applyStartupPolicy prepares the paste account before anything is mirrored.
+// Under adopt and reconcile, existing positions are left for the IOC reconcile, which
+// only trades the residual drift against the copy target.
+func applyStartupPolicy(exchange Exchange, cfg *config.HyperformanceConfig) error {
+	switch cfg.StartupPolicy {
+	case config.StartupFlatten, "":
+		exchange.CancelAllOrders()
+		for coin := range cfg.CoinRiskMap {
+			exchange.ClosePosition(coin)
+		}
+	case config.StartupReconcile:
+		exchange.CancelAllOrders()
+	case config.StartupAdopt:
+		// Without the ALO engine nothing would ever cancel adopted orders.
+		if cfg.DisableAloEngine {
+			exchange.CancelAllOrders()
+		}
+	default:
+		return fmt.Errorf("unknown startup_policy %q, want %s, %s or %s",
+			cfg.StartupPolicy, config.StartupFlatten, config.StartupReconcile, config.StartupAdopt)
+	}
+	return nil
+}
+
+// adoptPasteOrders runs on the first paste webData2 under the adopt policy, once the
+// copy webData2 is in. Orders this bot's ALO engine minted are kept, and the ALO
+// reconcile cancels those whose copy order is gone; its trigger orders are left to the
+// trigger engine and other bots' orders are left alone. An order placed before the cloid
+// codec carries the cloid of the copy ALO order it mirrors, and is linked to it while
+// that is still open. Any other order on an enabled coin, with no cloid or one minted
+// outside the codec, can't be matched to a copy order and is canceled.
+func (engine *AloEngine) adoptPasteOrders(pasteWd2 *models.WebData2Message) {
+	manager := engine.manager
+	copyOrders := manager.CopyWd2.OrdersByCloid()
+	adopted := 0
+	for _, order := range pasteWd2.Orders() {
+		if !manager.IsEnabledCoin(order.Coin) {
+			continue
+		}
+		if manager.OwnsCloid(order.Cloid, cloid.EngineAlo) {
+			adopted++
+			continue
+		}
+		if copyOrder, legacy := copyOrders[order.Cloid]; legacy && order.Cloid != "" && copyOrder.Tif == hl.TifAlo {
+			engine.linkCloids(order.Cloid, order.Cloid)
+			adopted++
+			continue
+		}
+		if _, tagged := cloid.Decode(order.Cloid); tagged {
+			continue
+		}
+		resp, err := manager.Client.CancelOrderByOID(order.Coin, int(order.Oid))
+		if err != nil {
+			logger.LogErrorf("[Startup] paste cancel untagged order %s oid=%d failed: %v", order.Coin, order.Oid, err)
+		} else if resp.Status != "ok" {
+			logger.LogErrorf("[Startup] paste cancel untagged order %s oid=%d returned status %q", order.Coin, order.Oid, resp.Status)
+		}
+	}
+	logger.LogInfof("[Startup] paste adopted %d orders and %d positions", adopted, len(pasteWd2.PositionsByCoin()))
+}
@@ -0,0 +1,180 @@
+package ws
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/store"
+)
+
+const (
+	defaultStateRetention = 24 * time.Hour
+	// pruneEvery is how many inserts into a dedup map trigger a sweep of expired keys.
+	pruneEvery = 1024
+)
+
+// restoreState reloads this Manager's dedup maps and cloid links from its Store.
+func (manager *Manager) restoreState() {
+	if manager.Store == nil {
+		return
+	}
+	account := manager.PasteAddress
+	for _, entry := range manager.Store.Entries(account, store.KindUsedPasteWd2) {
+		if chTime, err := strconv.ParseInt(entry.Key, 10, 64); err == nil {
+			manager.UsedPasteWd2[chTime] = entry.Time()
+		}
+	}
+	for _, entry := range manager.Store.Entries(account, store.KindUsedCopyAloCreate) {
+		if chTime, err := strconv.ParseInt(entry.Key, 10, 64); err == nil {
+			manager.UsedCopyAloCreates[chTime] = entry.Time()
+		}
+	}
+	engine := manager.AloEngine
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	for _, entry := range manager.Store.Entries(account, store.KindCreatedCloid) {
+		engine.createdCloids[entry.Key] = entry.Time()
+	}
+	for _, entry := range manager.Store.Entries(account, store.KindCanceledCloid) {
+		engine.canceledCloids[entry.Key] = entry.Time()
+	}
+	for _, entry := range manager.Store.Entries(account, store.KindCloidLink) {
+		// links to cloids of another bot id are stale; those that don't decode point at
+		// orders placed before the cloid codec and still mirror their copy order
+		if _, tagged := cloid.Decode(entry.Value); tagged && !manager.OwnsCloid(entry.Value, cloid.EngineAlo) {
+			continue
+		}
+		engine.pasteCloids[entry.Key] = entry.Value
+		engine.copyCloids[entry.Value] = entry.Key
+	}
+}
+
+// journal appends an entry for this paste account, if a state file is configured.
+func (manager *Manager) journal(kind store.Kind, key, value string) {
+	if manager.Store == nil {
+		return
+	}
+	if err := manager.Store.Put(manager.PasteAddress, kind, key, value); err != nil {
+		logger.LogErrorf("[State] paste journal %s %s failed: %v", kind, key, err)
+	}
+}
+
+// unjournal drops the entry of key for this paste account, if a state file is configured.
+func (manager *Manager) unjournal(kind store.Kind, key string) {
+	if manager.Store == nil {
+		return
+	}
+	if err := manager.Store.Delete(manager.PasteAddress, kind, key); err != nil {
+		logger.LogErrorf("[State] paste unjournal %s %s failed: %v", kind, key, err)
+	}
+}
+
+func (manager *Manager) journalTime(kind store.Kind, chTime int64) {
+	manager.journal(kind, strconv.FormatInt(chTime, 10), "")
+}
+
+// markUsed records key in used, sweeping keys older than the state retention every
+// pruneEvery inserts so the map stays bounded.
+func markUsed[K comparable](used map[K]time.Time, key K, retention time.Duration) {
+	used[key] = time.Now()
+	if len(used)%pruneEvery != 0 {
+		return
+	}
+	cutoff := time.Now().Add(-retention)
+	for k, at := range used {
+		if at.Before(cutoff) {
+			delete(used, k)
+		}
+	}
+}
+
+func (engine *AloEngine) linkCloids(copyCloid, pasteCloid string) {
+	engine.mu.Lock()
+	engine.linkLocked(copyCloid, pasteCloid)
+	engine.mu.Unlock()
+}
+
+func (engine *AloEngine) linkLocked(copyCloid, pasteCloid string) {
+	if engine.pasteCloids[copyCloid] == pasteCloid {
+		return
+	}
+	engine.pasteCloids[copyCloid] = pasteCloid
+	engine.copyCloids[pasteCloid] = copyCloid
+	engine.manager.journal(store.KindCloidLink, copyCloid, pasteCloid)
+}
+
+// pruneLinksLocked drops the links whose copy and paste orders have both closed. A link
+// lives while either is open: the copy order may still be modified, and the paste order
+// canceled, through it.
+func (engine *AloEngine) pruneLinksLocked() {
+	if engine.manager.CopyWd2 == nil || engine.manager.PasteWd2 == nil {
+		return
+	}
+	copyOpen := engine.manager.CopyWd2.OrdersByCloid()
+	pasteOpen := engine.manager.PasteWd2.OrdersByCloid()
+	for copyCloid, pasteCloid := range engine.pasteCloids {
+		if _, open := copyOpen[copyCloid]; open {
+			continue
+		}
+		if _, open := pasteOpen[pasteCloid]; open {
+			continue
+		}
+		delete(engine.pasteCloids, copyCloid)
+		if engine.copyCloids[pasteCloid] == copyCloid {
+			delete(engine.copyCloids, pasteCloid)
+		}
+		engine.manager.unjournal(store.KindCloidLink, copyCloid)
+	}
+}
+
+// unmarkCreated forgets copy cloids whose paste orders were never sent, and queues them
+// for the next reconcile while the copy account still has them open.
+func (engine *AloEngine) unmarkCreated(copyCloids []string) {
+	if len(copyCloids) == 0 {
+		return
+	}
+	engine.mu.Lock()
+	for _, copyCloid := range copyCloids {
+		delete(engine.createdCloids, copyCloid)
+		engine.retry[copyCloid] = true
+	}
+	engine.mu.Unlock()
+	for _, copyCloid := range copyCloids {
+		engine.manager.unjournal(store.KindCreatedCloid, copyCloid)
+	}
+}
+
+func (engine *AloEngine) markCanceled(cloid string) {
+	engine.mu.Lock()
+	markUsed(engine.canceledCloids, cloid, engine.manager.stateRetention)
+	engine.mu.Unlock()
+	engine.manager.journal(store.KindCanceledCloid, cloid, "")
+}
+
+// MintPasteCloid returns the paste cloid mirroring copyCloid and links the two, so the
+// link stays authoritative once an order is sent under it.
+func (engine *AloEngine) MintPasteCloid(copyCloid string) string {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	pasteCloid := engine.pasteCloidLocked(copyCloid)
+	engine.linkLocked(copyCloid, pasteCloid)
+	return pasteCloid
+}
+
+// pasteCloidLocked is the paste cloid mirroring copyCloid: the linked one if it was
+// placed, else the ALO cloid minted from the copy cloid's low 64 bits with the first
+// sequence no other copy cloid is linked to. Copy cloids that differ only in their high
+// bits so get paste cloids of their own.
+func (engine *AloEngine) pasteCloidLocked(copyCloid string) string {
+	if pasteCloid, ok := engine.pasteCloids[copyCloid]; ok {
+		return pasteCloid
+	}
+	id := cloid.ID{BotID: engine.manager.Config.BotID, Engine: cloid.EngineAlo, Leader: primaryLeader, Source: cloid.Low64(copyCloid)}
+	for ; ; id.Seq++ {
+		pasteCloid := id.String()
+		if linked, taken := engine.copyCloids[pasteCloid]; !taken || linked == copyCloid {
+			return pasteCloid
+		}
+	}
+}
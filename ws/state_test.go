@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/store"
+)
+
+func TestAloLinksLiveWhileEitherOrderIsOpen(t *testing.T) {
+	manager, _ := newTestManager(t, nil)
+	stateStore, err := store.Open(filepath.Join(t.TempDir(), "state.jsonl"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stateStore.Close() })
+	manager.Store = stateStore
+	feed(t, manager, 1000, 100000, nil, nil)
+
+	const (
+		copyOpen   = "0x000000000000000000000000000000c1"
+		copyClosed = "0x000000000000000000000000000000c2"
+		bothClosed = "0x000000000000000000000000000000c3"
+	)
+	engine := manager.AloEngine
+	pasteOpen := engine.MintPasteCloid(copyClosed)
+	engine.MintPasteCloid(copyOpen)
+	engine.MintPasteCloid(bothClosed)
+
+	resting := func(c string) hl.Order {
+		return hl.Order{Coin: "BTC", Side: "B", LimitPx: 99000, Sz: 0.01, OrigSz: 0.01, Oid: 1, Cloid: c, Tif: hl.TifAlo, OrderType: "Limit"}
+	}
+	manager.handleWebData2Payload(testWd2Frame(t, testPasteAddress, 2000, 100000, nil, resting(pasteOpen)))
+	manager.handleWebData2Payload(testWd2Frame(t, testCopyAddress, 2000, 100000, nil, resting(copyOpen)))
+	engine.RunAloReconcile()
+
+	engine.mu.Lock()
+	_, keptCopyOpen := engine.pasteCloids[copyOpen]
+	_, keptPasteOpen := engine.pasteCloids[copyClosed]
+	_, keptClosed := engine.pasteCloids[bothClosed]
+	engine.mu.Unlock()
+	if !keptCopyOpen || !keptPasteOpen {
+		t.Errorf("links kept: copy open %v, paste open %v, want both", keptCopyOpen, keptPasteOpen)
+	}
+	if keptClosed {
+		t.Error("the link of two closed orders was kept")
+	}
+	if links := stateStore.Entries(testPasteAddress, store.KindCloidLink); len(links) != 2 {
+		t.Errorf("journaled links = %+v, want the two open ones", links)
+	}
+}
@@ -0,0 +1,442 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/models"
+	"github.com/itay747/hyperformance/utils"
+)
+
+// TriggerEngine mirrors the primary copy account's trigger orders: stop and take profit
+// orders, market or limit, and position TP/SL. Each copy trigger is mirrored by a paste
+// trigger with a trigger engine cloid of its oid, moved with BulkModifyOrdersByCloid when
+// the leader moves it and canceled when the leader cancels it. Children of a copy order
+// (a TP/SL attached to an entry) are mirrored once the entry fills and they go live.
+//
+// A copy trigger that fires leaves the copy book like a canceled one; it is told apart by
+// its fills. A fired reduce-only trigger's mirror is left to fire on paste too, while any
+// other fired trigger's mirror is canceled and its fills are mirrored as an IOC instead.
+type TriggerEngine struct {
+	mu      sync.Mutex
+	manager *Manager
+	enabled bool
+	// links maps a copy trigger oid to its paste mirror.
+	links map[int64]triggerLink
+	// unsure holds vanished copy triggers whose copy position moved before their fills
+	// arrived. They are decided on the next snapshot.
+	unsure map[int64]triggerLink
+	// fired maps the paste cloid of a reduce-only mirror, left open after its copy trigger
+	// fired, to when it did. It is canceled if it hasn't fired within firedGrace.
+	fired map[string]time.Time
+	// triggered holds the copy oids that fired, whose fills this engine mirrors.
+	triggered map[int64]time.Time
+}
+
+// firedGrace is how long a fired copy trigger's reduce-only mirror is given to fire on
+// paste before it is canceled and the drift left to the IOC reconcile loop.
+const firedGrace = 30 * time.Second
+
+type triggerLink struct {
+	pasteCloid string
+	copyOrder  hl.Order
+}
+
+func NewTriggerEngine(ctx context.Context, m *Manager, enabled bool) *TriggerEngine {
+	return &TriggerEngine{
+		manager:   m,
+		enabled:   enabled,
+		links:     make(map[int64]triggerLink),
+		unsure:    make(map[int64]triggerLink),
+		fired:     make(map[string]time.Time),
+		triggered: make(map[int64]time.Time),
+	}
+}
+
+func (engine *TriggerEngine) Start(ctx context.Context, CopyWd2Chan <-chan *models.WebData2Message) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case wd2 := <-CopyWd2Chan:
+				// Trigger orders are only mirrored from the primary copy account
+				if !engine.enabled || wd2.Data.User != engine.manager.CopyAddress {
+					continue
+				}
+				engine.HandleTriggerReconcile()
+			}
+		}
+	}()
+}
+
+// Mirrors reports whether the copy order oid is a trigger mirrored by this engine, so
+// the IOC and fill engines don't copy its fills a second time.
+func (engine *TriggerEngine) Mirrors(oid int64) bool {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	_, linked := engine.links[oid]
+	_, unsure := engine.unsure[oid]
+	_, triggered := engine.triggered[oid]
+	return linked || unsure || triggered
+}
+
+func (engine *TriggerEngine) HandleTriggerReconcile() {
+	manager := engine.manager
+	if manager.Halted() || manager.EnginePaused(EngineTrigger) || manager.CopyWd2 == nil || manager.PasteWd2 == nil {
+		return
+	}
+	creates, modifies, cancels, fills := engine.RunTriggerReconcile()
+	if len(cancels) > 0 {
+		engine.processTriggerCancels(cancels)
+	}
+	if len(fills) > 0 {
+		manager.IocEngine.SendIocOrders(fills)
+	}
+	if len(modifies) > 0 {
+		engine.processTriggerModifies(modifies)
+	}
+	if len(creates) > 0 {
+		engine.processTriggerCreates(creates)
+	}
+}
+
+// RunTriggerReconcile diffs the copy trigger orders against the links. A copy trigger
+// that vanished in the same snapshot a new one appeared on the same coin, side and kind
+// was moved by the leader, and its paste mirror is modified rather than replaced. fills
+// are the IOC mirrors of fired copy triggers whose paste mirrors are canceled.
+func (engine *TriggerEngine) RunTriggerReconcile() (creates, modifies []hl.OrderRequest, cancels map[string]hl.Order, fills []hl.Order) {
+	manager := engine.manager
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	copyTriggers := make(map[int64]hl.Order)
+	for _, order := range manager.CopyWd2.Orders() {
+		if order.IsTrigger && manager.IsEnabledCoin(order.Coin) {
+			copyTriggers[order.Oid] = order
+		}
+	}
+	pasteTriggers := make(map[string]hl.Order)
+	for _, order := range manager.PasteWd2.Orders() {
+		if manager.OwnsCloid(order.Cloid, cloid.EngineTrigger) {
+			pasteTriggers[order.Cloid] = order
+		}
+	}
+
+	vanished := make(map[int64]triggerLink)
+	deferred := make(map[int64]bool, len(engine.unsure))
+	for oid, link := range engine.unsure {
+		vanished[oid] = link
+		deferred[oid] = true
+		delete(engine.unsure, oid)
+	}
+	for oid, link := range engine.links {
+		if _, open := copyTriggers[oid]; !open {
+			vanished[oid] = link
+			delete(engine.links, oid)
+		}
+	}
+	for oid, copyOrder := range copyTriggers {
+		link, linked := engine.links[oid]
+		if linked {
+			if !sameTrigger(link.copyOrder, copyOrder) {
+				if request, ok := engine.triggerRequest(copyOrder, link.pasteCloid); ok {
+					modifies = append(modifies, request)
+				}
+				engine.links[oid] = triggerLink{pasteCloid: link.pasteCloid, copyOrder: copyOrder}
+			}
+			continue
+		}
+		if movedOid, moved := movedTrigger(vanished, pasteTriggers, copyOrder); moved {
+			pasteCloid := vanished[movedOid].pasteCloid
+			delete(vanished, movedOid)
+			if request, ok := engine.triggerRequest(copyOrder, pasteCloid); ok {
+				modifies = append(modifies, request)
+			}
+			engine.links[oid] = triggerLink{pasteCloid: pasteCloid, copyOrder: copyOrder}
+			continue
+		}
+		pasteCloid := manager.NewPasteCloid(cloid.EngineTrigger, primaryLeader, uint64(oid))
+		if _, open := pasteTriggers[pasteCloid]; open {
+			// mirrored before a restart
+			engine.links[oid] = triggerLink{pasteCloid: pasteCloid, copyOrder: copyOrder}
+			continue
+		}
+		if request, ok := engine.triggerRequest(copyOrder, pasteCloid); ok {
+			creates = append(creates, request)
+			engine.links[oid] = triggerLink{pasteCloid: pasteCloid, copyOrder: copyOrder}
+		}
+	}
+
+	cancels = make(map[string]hl.Order)
+	for oid, link := range vanished {
+		pasteOrder, open := pasteTriggers[link.pasteCloid]
+		if _, _, _, filled := manager.FillEngine.Filled(oid); !filled {
+			if !deferred[oid] && copyPositionMoved(manager.CopyWd2, link.copyOrder.Coin) {
+				engine.unsure[oid] = link
+			} else if open {
+				cancels[link.pasteCloid] = pasteOrder
+			}
+			continue
+		}
+		markUsed(engine.triggered, oid, manager.stateRetention)
+		if !open {
+			// the paste mirror fired too
+			continue
+		}
+		if link.copyOrder.ReduceOnly || link.copyOrder.IsPositionTpsl {
+			logger.LogInfof("[Trigger] copy %s oid=%d fired => paste cloid=%s left to fire", link.copyOrder.Coin, oid, link.pasteCloid)
+			engine.fired[link.pasteCloid] = time.Now()
+			continue
+		}
+		cancels[link.pasteCloid] = pasteOrder
+		if order, ok := engine.fillMirror(oid, link.copyOrder); ok {
+			logger.LogFields("info", fmt.Sprintf("[Trigger] copy %s oid=%d fired => paste IOC %s sz=%v", order.Coin, oid, order.Side, order.Sz), utils.OrderFields(order))
+			fills = append(fills, order)
+		}
+	}
+	linkedCloids := make(map[string]bool, len(engine.links)+len(engine.unsure)+len(engine.fired))
+	for _, link := range engine.links {
+		linkedCloids[link.pasteCloid] = true
+	}
+	for _, link := range engine.unsure {
+		linkedCloids[link.pasteCloid] = true
+	}
+	for cloid, firedAt := range engine.fired {
+		if _, open := pasteTriggers[cloid]; !open || time.Since(firedAt) > firedGrace {
+			delete(engine.fired, cloid)
+			continue
+		}
+		linkedCloids[cloid] = true
+	}
+	for cloid, pasteOrder := range pasteTriggers {
+		if !linkedCloids[cloid] {
+			cancels[cloid] = pasteOrder
+		}
+	}
+	return creates, modifies, cancels, fills
+}
+
+// fillMirror builds the paste IOC mirroring the fills of a fired copy trigger, under the
+// IOC cloid the IOC engine would have given them.
+func (engine *TriggerEngine) fillMirror(oid int64, copyOrder hl.Order) (hl.Order, bool) {
+	manager := engine.manager
+	size, avgPx, filledAt, ok := manager.FillEngine.Filled(oid)
+	if !ok {
+		return hl.Order{}, false
+	}
+	filled := hl.Order{Coin: copyOrder.Coin, Side: copyOrder.Side, Sz: size}
+	sz := hl.SizeToFloat(manager.scaleFill(filled, filledAt), manager.Decimals(copyOrder.Coin))
+	if sz == 0 || Round2(sz*avgPx) < minNotionalDiff {
+		return hl.Order{}, false
+	}
+	return hl.Order{
+		Coin:      copyOrder.Coin,
+		Side:      copyOrder.Side,
+		Sz:        sz,
+		LimitPx:   avgPx,
+		Cloid:     manager.NewPasteCloid(cloid.EngineIoc, primaryLeader, uint64(oid)),
+		Tif:       hl.TifFrontendMarket,
+		OrderType: hl.TifFrontendMarket,
+	}, true
+}
+
+// copyPositionMoved reports whether the copy position on coin changed since the previous
+// snapshot, the sign of a vanished trigger that fired before its fills arrived.
+func copyPositionMoved(copyWd2 *models.WebData2Message, coin string) bool {
+	prev := copyWd2.Last()
+	if prev == nil {
+		return false
+	}
+	return copyWd2.PositionsByCoin()[coin].Szi != prev.PositionsByCoin()[coin].Szi
+}
+
+// triggerRequest builds the paste mirror of a copy trigger order. Position TP/SL without
+// a size cover the whole paste position, like the leader's.
+func (engine *TriggerEngine) triggerRequest(copyOrder hl.Order, pasteCloid string) (hl.OrderRequest, bool) {
+	manager := engine.manager
+	var sz float64
+	if !copyOrder.IsPositionTpsl || copyOrder.Sz != 0 {
+		sz = hl.SizeToFloat(math.Abs(manager.scaleSize(copyOrder)), manager.Decimals(copyOrder.Coin))
+		if sz == 0 {
+			logger.LogInfof("[Trigger] paste %s oid=%d sized to 0 => skipping", copyOrder.Coin, copyOrder.Oid)
+			return hl.OrderRequest{}, false
+		}
+	}
+	tpsl := hl.TriggerSl
+	if strings.HasPrefix(copyOrder.OrderType, "Take Profit") {
+		tpsl = hl.TriggerTp
+	}
+	triggerPx := manager.SnapPrice(copyOrder.Coin, copyOrder.TriggerPx)
+	limitPx := copyOrder.LimitPx
+	if limitPx <= 0 {
+		limitPx = copyOrder.TriggerPx
+	}
+	return hl.OrderRequest{
+		Coin:    copyOrder.Coin,
+		IsBuy:   copyOrder.Side == "B",
+		Sz:      sz,
+		LimitPx: manager.SnapPrice(copyOrder.Coin, limitPx),
+		OrderType: hl.OrderType{Trigger: &hl.TriggerOrderType{
+			IsMarket:  strings.HasSuffix(copyOrder.OrderType, "Market"),
+			TriggerPx: hl.PriceToWire(triggerPx, hl.PERP_MAX_DECIMALS, manager.Decimals(copyOrder.Coin)),
+			TpSl:      tpsl,
+		}},
+		ReduceOnly: copyOrder.ReduceOnly || copyOrder.IsPositionTpsl,
+		Cloid:      pasteCloid,
+	}, true
+}
+
+func (engine *TriggerEngine) processTriggerCreates(requests []hl.OrderRequest) {
+	manager := engine.manager
+	allowed := engine.gate(requests)
+
+	var grouped, positionTpsl []hl.OrderRequest
+	for _, request := range allowed {
+		if request.Sz == 0 {
+			positionTpsl = append(positionTpsl, request)
+		} else {
+			grouped = append(grouped, request)
+		}
+	}
+	manager.syncLeverage(grouped)
+	engine.placeTriggers(grouped, hl.GroupingNa)
+	engine.placeTriggers(positionTpsl, hl.GroupingTpSl)
+}
+
+// gate returns the requests the risk gate allows and unlinks the rest, so a rejected
+// trigger is tried afresh on the next copy webData2.
+func (engine *TriggerEngine) gate(requests []hl.OrderRequest) []hl.OrderRequest {
+	allowed := engine.manager.RiskGate.Filter(requests)
+	passed := make(map[string]bool, len(allowed))
+	for _, request := range allowed {
+		passed[request.Cloid] = true
+	}
+	var rejected []hl.OrderRequest
+	for _, request := range requests {
+		if !passed[request.Cloid] {
+			rejected = append(rejected, request)
+		}
+	}
+	engine.unlink(rejected)
+	return allowed
+}
+
+func (engine *TriggerEngine) placeTriggers(requests []hl.OrderRequest, grouping hl.Grouping) {
+	if len(requests) == 0 {
+		return
+	}
+	resp, err := engine.manager.bulkOrders(EngineTrigger, requests, grouping)
+	if err != nil {
+		logger.LogErrorf("[Trigger] paste BulkOrders error => %v", err)
+		engine.unlink(requests)
+		return
+	}
+	if resp.Status != "ok" {
+		logger.LogErrorf("[Trigger] paste BulkOrders status not ok => %s", resp.Status)
+		engine.unlink(requests)
+		return
+	}
+	var failed []hl.OrderRequest
+	for i, status := range resp.Response.Data.Statuses {
+		if status.Error != "" && i < len(requests) {
+			logger.LogErrorf("[Trigger] paste %s %s trigger=%s => %s", requests[i].Coin,
+				sideName(requests[i].IsBuy), requests[i].OrderType.Trigger.TriggerPx, status.Error)
+			failed = append(failed, requests[i])
+		}
+	}
+	engine.unlink(failed)
+}
+
+// unlink drops the links of paste triggers that failed to place or move. The next copy
+// webData2 places them afresh and cancels what is left of the old mirror.
+func (engine *TriggerEngine) unlink(requests []hl.OrderRequest) {
+	if len(requests) == 0 {
+		return
+	}
+	failed := make(map[string]bool, len(requests))
+	for _, request := range requests {
+		failed[request.Cloid] = true
+	}
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	for oid, link := range engine.links {
+		if failed[link.pasteCloid] {
+			delete(engine.links, oid)
+		}
+	}
+}
+
+func (engine *TriggerEngine) processTriggerModifies(requests []hl.OrderRequest) {
+	requests = engine.gate(requests)
+	if len(requests) == 0 {
+		return
+	}
+	resp, err := engine.manager.bulkModifyOrdersByCloid(EngineTrigger, requests)
+	if err != nil {
+		logger.LogErrorf("[Trigger] paste BulkModifyOrdersByCloid error => %v", err)
+		engine.unlink(requests)
+		return
+	}
+	if resp.Status != "ok" {
+		logger.LogErrorf("[Trigger] paste BulkModifyOrdersByCloid status not ok => %s", resp.Status)
+		engine.unlink(requests)
+		return
+	}
+	var failed []hl.OrderRequest
+	for i, status := range resp.Response.Data.Statuses {
+		if status.Error != "" && i < len(requests) {
+			logger.LogErrorf("[Trigger] paste modify %s cloid=%s => %s", requests[i].Coin, requests[i].Cloid, status.Error)
+			failed = append(failed, requests[i])
+		}
+	}
+	engine.unlink(failed)
+}
+
+func (engine *TriggerEngine) processTriggerCancels(cancels map[string]hl.Order) {
+	manager := engine.manager
+	var byCloid []hl.CancelCloidWire
+	for cloid, order := range cancels {
+		byCloid = append(byCloid, hl.CancelCloidWire{Asset: manager.MetaMap[order.Coin].AssetID, Cloid: cloid})
+	}
+	resp, err := manager.Client.BulkCancelOrdersByCloid(byCloid)
+	if err != nil {
+		logger.LogErrorf("[Trigger] paste BulkCancelOrdersByCloid error => %v", err)
+		return
+	}
+	if resp.Status != "ok" {
+		logger.LogErrorf("[Trigger] paste BulkCancelOrdersByCloid status not ok => %s", resp.Status)
+		return
+	}
+	for i, status := range resp.Response.Data.Statuses {
+		if status.Error != "" && i < len(byCloid) {
+			logger.LogErrorf("[Trigger] paste cancel %s cloid=%s => %s", cancels[byCloid[i].Cloid].Coin, byCloid[i].Cloid, status.Error)
+		}
+	}
+}
+
+func sameTrigger(a, b hl.Order) bool {
+	return a.TriggerPx == b.TriggerPx && a.LimitPx == b.LimitPx && a.Sz == b.Sz
+}
+
+// movedTrigger finds a vanished copy trigger, whose paste mirror is still open, that the
+// leader replaced with order.
+func movedTrigger(vanished map[int64]triggerLink, pasteTriggers map[string]hl.Order, order hl.Order) (int64, bool) {
+	for oid, link := range vanished {
+		if _, open := pasteTriggers[link.pasteCloid]; !open {
+			continue
+		}
+		prev := link.copyOrder
+		if prev.Coin == order.Coin && prev.Side == order.Side && prev.OrderType == order.OrderType &&
+			prev.IsPositionTpsl == order.IsPositionTpsl {
+			return oid, true
+		}
+	}
+	return 0, false
+}
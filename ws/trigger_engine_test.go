@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"testing"
+
+	hl "github.com/Logarithm-Labs/go-hyperliquid/hyperliquid"
+	"github.com/itay747/hyperformance/cloid"
+	"github.com/itay747/hyperformance/config"
+)
+
+func TestTriggerModifiesPassTheRiskGate(t *testing.T) {
+	manager, exchange := newTestManager(t, func(cfg *config.HyperformanceConfig) {
+		cfg.Risk = config.RiskConfig{MaxOrderNotional: 20000}
+	})
+	feed(t, manager, 1000, 100000, nil, nil)
+	engine := manager.TriggerEngine
+
+	stop := func(oid int64, sz float64) hl.OrderRequest {
+		request := order(true, sz, 101000, "", manager.NewPasteCloid(cloid.EngineTrigger, primaryLeader, uint64(oid)))
+		request.OrderType = hl.OrderType{Trigger: &hl.TriggerOrderType{TriggerPx: "100500", TpSl: "sl"}}
+		engine.links[oid] = triggerLink{pasteCloid: request.Cloid, copyOrder: hl.Order{Coin: "BTC", Oid: oid}}
+		return request
+	}
+	within := stop(1, 0.1)
+	beyond := stop(2, 0.5)
+	engine.processTriggerModifies([]hl.OrderRequest{within, beyond})
+
+	if len(exchange.modifies) != 1 || exchange.modifies[0].Cloid != within.Cloid {
+		t.Fatalf("modified %+v, want only the 0.1 BTC trigger", exchange.modifies)
+	}
+	if _, linked := engine.links[2]; linked {
+		t.Error("the rejected trigger is still linked, want it placed afresh next snapshot")
+	}
+	if _, linked := engine.links[1]; !linked {
+		t.Error("the modified trigger lost its link")
+	}
+	if rejected := manager.RiskGate.Rejections()[RiskOrderNotional]; rejected != 1 {
+		t.Errorf("order notional rejections = %d, want 1", rejected)
+	}
+}